@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/database"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/maintenance"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+func main() {
+	log.Println("Starting Maintenance Job...")
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Connect to PostgreSQL
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Apply pending schema migrations
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	partitionManager, err := database.NewPartitionManager(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create partition manager: %v", err)
+	}
+
+	job, err := maintenance.NewJob(repository.NewMaintenanceRepository(db), partitionManager, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create maintenance job: %v", err)
+	}
+
+	// Handle graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		job.Run(ctx, cfg.MaintenanceInterval)
+		close(done)
+	}()
+
+	log.Printf("Maintenance job running every %s", cfg.MaintenanceInterval)
+
+	<-quit
+	log.Println("Shutting down maintenance job...")
+	cancel()
+	<-done
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"os/signal"
@@ -18,6 +19,12 @@ type VehicleLocation struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	Timestamp int64   `json:"timestamp"`
+	Heading   float64 `json:"heading"`
+	HDOP      float64 `json:"hdop"`
+	Accuracy  float64 `json:"accuracy"`
+	Odometer  float64 `json:"odometer"`
+	Ignition  bool    `json:"ignition"`
+	DoorOpen  bool    `json:"door_open"`
 }
 
 func main() {
@@ -55,9 +62,12 @@ func main() {
 	baseLat := -6.1938148
 	baseLon := 106.8230342
 
-	// Track current position for each vehicle
+	// Track current position and odometer for each vehicle
 	vehiclePositions := make(map[string][2]float64)
+	vehicleOdometers := make(map[string]float64)
 	for _, v := range vehicles {
+		vehicleOdometers[v] = 10000 + rand.Float64()*50000
+
 		// Initialize each vehicle at a random position near base
 		vehiclePositions[v] = [2]float64{
 			baseLat + (rand.Float64()-0.5)*0.01,
@@ -78,6 +88,7 @@ func main() {
 			for _, vehicleID := range vehicles {
 				// Get current position
 				pos := vehiclePositions[vehicleID]
+				prev := pos
 
 				// Randomly move the vehicle (simulate movement)
 				pos[0] += (rand.Float64() - 0.5) * 0.0001
@@ -92,11 +103,23 @@ func main() {
 
 				vehiclePositions[vehicleID] = pos
 
+				// Approximate distance and heading on a flat plane (1 degree ~ 111 km)
+				dLat := (pos[0] - prev[0]) * 111.32
+				dLon := (pos[1] - prev[1]) * 111.32 * math.Cos(pos[0]*math.Pi/180)
+				vehicleOdometers[vehicleID] += math.Hypot(dLat, dLon)
+				heading := math.Mod(math.Atan2(dLon, dLat)*180/math.Pi+360, 360)
+
 				location := VehicleLocation{
 					VehicleID: vehicleID,
 					Latitude:  pos[0],
 					Longitude: pos[1],
 					Timestamp: time.Now().Unix(),
+					Heading:   math.Round(heading*10) / 10,
+					HDOP:      math.Round((0.8+rand.Float64())*10) / 10,
+					Accuracy:  math.Round((3+rand.Float64()*7)*10) / 10,
+					Odometer:  math.Round(vehicleOdometers[vehicleID]*1000) / 1000,
+					Ignition:  true,
+					DoorOpen:  rand.Float64() < 0.05,
 				}
 
 				payload, err := json.Marshal(location)
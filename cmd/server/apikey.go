@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/auth"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/database"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+const apiKeyUsage = `Usage: server apikey <command>
+
+Commands:
+  create <name> <role>  create a key with role viewer, dispatcher or admin
+  list                  list keys
+  revoke <id>           revoke a key`
+
+// runAPIKey handles the apikey subcommand, used to create the first admin
+// key before any key exists to call the API with
+func runAPIKey(args []string) {
+	if len(args) == 0 {
+		fmt.Println(apiKeyUsage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	repo := repository.NewAPIKeyRepository(db)
+
+	switch {
+	case args[0] == "create" && len(args) == 3:
+		key, hash, err := auth.NewAPIKey(args[1], args[2], nil)
+		if err != nil {
+			log.Fatalf("Invalid api key: %v", err)
+		}
+		if err := repo.CreateAPIKey(&key.APIKey, hash); err != nil {
+			log.Fatalf("Failed to create api key: %v", err)
+		}
+
+		fmt.Printf("Created %s key %s (%s)\n", key.Role, key.ID, key.Name)
+		fmt.Printf("Key: %s\n", key.Key)
+		fmt.Println("Store it now, it can't be shown again.")
+
+	case args[0] == "list" && len(args) == 1:
+		keys, err := repo.ListAPIKeys()
+		if err != nil {
+			log.Fatalf("Failed to list api keys: %v", err)
+		}
+
+		for _, key := range keys {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked " + time.Unix(*key.RevokedAt, 0).UTC().Format(time.RFC3339)
+			} else if key.ExpiresAt != nil && *key.ExpiresAt <= time.Now().Unix() {
+				status = "expired"
+			}
+			fmt.Printf("%s  %-12s %-10s %-30s %s\n", key.ID, key.Prefix, key.Role, key.Name, status)
+		}
+
+	case args[0] == "revoke" && len(args) == 2:
+		found, err := repo.RevokeAPIKey(args[1])
+		if err != nil {
+			log.Fatalf("Failed to revoke api key: %v", err)
+		}
+		if !found {
+			log.Fatalf("No active api key %s", args[1])
+		}
+		log.Printf("Revoked api key %s", args[1])
+
+	default:
+		fmt.Println(apiKeyUsage)
+		os.Exit(2)
+	}
+}
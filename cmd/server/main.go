@@ -1,23 +1,39 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/api"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/auth"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/database"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/handlers"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/live"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/mqtt"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/rabbitmq"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/ratelimit"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/speed"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/trips"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		runAPIKey(os.Args[2:])
+		return
+	}
+
 	log.Println("Starting Fleet Management Backend...")
 
 	// Load configuration
@@ -30,13 +46,56 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize database schema
-	if err := database.InitSchema(db); err != nil {
-		log.Fatalf("Failed to initialize schema: %v", err)
+	// Apply pending schema migrations
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create upcoming location partitions and keep them maintained
+	partitionManager, err := database.NewPartitionManager(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create partition manager: %v", err)
 	}
+	if err := partitionManager.EnsurePartitions(time.Now()); err != nil {
+		// Locations still land in the default partition, and the partition
+		// manager retries on every check
+		log.Printf("Failed to create location partitions: %v", err)
+	}
+	if _, err := partitionManager.EnforceRetention(time.Now()); err != nil {
+		log.Printf("Failed to enforce location retention: %v", err)
+	}
+	go partitionManager.Run(ctx, cfg.PartitionCheckTime)
 
-	// Create repository
+	// Create repositories
 	vehicleRepo := repository.NewVehicleRepository(db)
+	geofenceRepo := repository.NewGeofenceRepository(db)
+	routeRepo := repository.NewRouteRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	tripRepo := repository.NewTripRepository(db)
+
+	// Create authenticator for API keys and JWTs
+	authenticator, err := auth.NewAuthenticator(apiKeyRepo, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create authenticator: %v", err)
+	}
+	if !cfg.AuthEnabled {
+		log.Println("Authentication is disabled, every request is treated as admin")
+	}
+
+	// Create rate limiters and drop idle buckets periodically
+	rateLimits := ratelimit.NewLimits(cfg)
+	go rateLimits.Run(ctx, time.Minute)
+
+	// Create batch writer for incoming locations
+	locationWriter := repository.NewLocationWriter(vehicleRepo, cfg.LocationBatchSize, cfg.LocationFlushInterval,
+		func(err error, failed int) {
+			log.Printf("Failed to save %d locations: %v", failed, err)
+		},
+	)
+	defer locationWriter.Close()
 
 	// Create RabbitMQ publisher
 	rabbitPublisher, err := rabbitmq.NewPublisher(cfg)
@@ -49,34 +108,119 @@ func main() {
 	defer rabbitPublisher.Close()
 
 	// Create geofence checker
-	geofenceChecker := geofence.NewChecker(cfg)
+	geofenceChecker, err := geofence.NewChecker(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create geofence checker: %v", err)
+	}
+
+	// Load geofences stored in the database and keep them in sync
+	geofenceSyncer := geofence.NewSyncer(geofenceChecker, geofenceRepo, cfg.GeofenceSyncTime)
+	if err := geofenceSyncer.Sync(); err != nil {
+		log.Fatalf("Failed to load geofences: %v", err)
+	}
+	log.Printf("Geofence checker ready with %d geofences", geofenceChecker.Len())
+
+	go geofenceSyncer.Run(ctx)
+
+	geofenceTracker := geofence.NewTracker(geofenceChecker, cfg.GeofenceDwellTime)
+
+	// Create route deviation detector with the stored route assignments
+	deviationDetector := geofence.NewDeviationDetector(
+		geofenceChecker, routeRepo, cfg.RouteDeviationDistance, cfg.RouteDeviationTime,
+	)
+	if err := deviationDetector.Sync(); err != nil {
+		log.Fatalf("Failed to load route assignments: %v", err)
+	}
+	go deviationDetector.Run(ctx, cfg.GeofenceSyncTime)
+
+	// Index current vehicle positions for spatial queries
+	pointIndex := geofence.NewPointIndex()
+	latest, err := vehicleRepo.ListLatestLocations(repository.LatestLocationFilter{})
+	if err != nil {
+		log.Fatalf("Failed to load latest locations: %v", err)
+	}
+	pointIndex.Load(latest)
+	log.Printf("Point index ready with %d vehicles", pointIndex.Len())
+
+	// Create hub for the live location feed
+	liveHub := live.NewLocationHub(deviationDetector.RouteOf, cfg.LiveClientBuffer)
+
+	// Create hub for the alert event stream
+	eventHub := live.NewEventHub(cfg.EventHistorySize, cfg.EventClientBuffer)
+
+	// Create trip detector and resume the trips in progress before the
+	// last shutdown
+	tripDetector := trips.NewDetector(geofenceChecker, cfg)
+	resumed, err := tripDetector.Restore(tripRepo, vehicleRepo, time.Now().Unix())
+	if err != nil {
+		log.Fatalf("Failed to restore trips: %v", err)
+	}
+	log.Printf("Trip detector ready with %d trips in progress", resumed)
+
+	// Create speed monitor for derived speed and overspeed detection
+	speedMonitor := speed.NewMonitor(geofenceChecker, cfg.SpeedLimit, cfg.OverspeedMinTime)
 
 	// Create MQTT subscriber with location handler
 	mqttSubscriber, err := mqtt.NewSubscriber(cfg, func(loc *models.VehicleLocation) {
-		// Save location to database
-		if err := vehicleRepo.SaveLocation(loc); err != nil {
-			log.Printf("Failed to save location: %v", err)
+		// Derive speed from the previous fix when the device doesn't report
+		// it; the derived speed is only used for the overspeed check
+		kmh := speedMonitor.DeriveSpeed(loc)
+
+		// Queue location for the next database batch
+		if err := locationWriter.Write(loc); err != nil {
+			log.Printf("Failed to queue location: %v", err)
 			return
 		}
-		log.Printf("Saved location for vehicle %s: lat=%f, lon=%f", loc.VehicleID, loc.Latitude, loc.Longitude)
-
-		// Check geofence
-		if geofenceChecker.IsInsideGeofence(loc) {
-			log.Printf("Vehicle %s entered geofence!", loc.VehicleID)
-
-			event := &models.GeofenceEvent{
-				VehicleID: loc.VehicleID,
-				Event:     "geofence_entry",
-				Location: models.Location{
-					Latitude:  loc.Latitude,
-					Longitude: loc.Longitude,
-				},
-				Timestamp: loc.Timestamp,
-			}
+		pointIndex.Update(loc)
+		liveHub.Publish(loc)
+
+		// Check geofence transitions
+		for _, event := range geofenceTracker.Process(loc) {
+			log.Printf("Vehicle %s %s geofence %s", loc.VehicleID, event.Event, event.GeofenceName)
 
 			if err := rabbitPublisher.PublishGeofenceEvent(event); err != nil {
 				log.Printf("Failed to publish geofence event: %v", err)
 			}
+			if err := eventHub.Publish(event.Event, event.VehicleID, event); err != nil {
+				log.Printf("Failed to stream geofence event: %v", err)
+			}
+		}
+
+		// Check route deviation
+		if event := deviationDetector.Process(loc); event != nil {
+			log.Printf("Vehicle %s %s %s (%.0fm)", loc.VehicleID, event.Event, event.RouteName, event.DistanceMeters)
+
+			if err := rabbitPublisher.PublishRouteEvent(event); err != nil {
+				log.Printf("Failed to publish route event: %v", err)
+			}
+			if err := eventHub.Publish(event.Event, event.VehicleID, event); err != nil {
+				log.Printf("Failed to stream route event: %v", err)
+			}
+		}
+
+		// Split the location stream into trips
+		for _, trip := range tripDetector.Process(loc) {
+			if trip.EndedAt != nil {
+				log.Printf("Vehicle %s ended trip %s: %.0fm in %ds (%s)",
+					loc.VehicleID, trip.ID, trip.DistanceMeters, trip.DurationSeconds, trip.EndReason)
+			}
+
+			if err := tripRepo.SaveTrip(trip); err != nil {
+				log.Printf("Failed to save trip: %v", err)
+			}
+		}
+
+		// Check overspeed
+		if event := speedMonitor.CheckOverspeed(loc, kmh); event != nil {
+			log.Printf("Vehicle %s %s: peak %.1f km/h (limit %.0f km/h) for %ds",
+				loc.VehicleID, event.Event, event.PeakSpeed, event.SpeedLimit, event.DurationSeconds)
+
+			if err := rabbitPublisher.PublishOverspeedEvent(event); err != nil {
+				log.Printf("Failed to publish overspeed event: %v", err)
+			}
+			if err := eventHub.Publish(event.Event, event.VehicleID, event); err != nil {
+				log.Printf("Failed to stream overspeed event: %v", err)
+			}
 		}
 	})
 	if err != nil {
@@ -94,9 +238,22 @@ func main() {
 		log.Fatalf("Failed to subscribe: %v", err)
 	}
 
-	// Setup API handler
-	vehicleHandler := handlers.NewVehicleHandler(vehicleRepo)
-	app := api.SetupRouter(vehicleHandler)
+	// Setup API handlers
+	app := api.SetupRouter(cfg, &api.Handlers{
+		Health: handlers.NewHealthHandler(map[string]func() int{
+			"ingest":          mqttSubscriber.QueueDepth,
+			"location_writer": locationWriter.Pending,
+		}),
+		Vehicle:  handlers.NewVehicleHandler(vehicleRepo, geofenceChecker, pointIndex),
+		Geofence: handlers.NewGeofenceHandler(geofenceRepo, geofenceChecker),
+		Route:    handlers.NewRouteHandler(routeRepo, geofenceChecker, deviationDetector),
+		Trip:     handlers.NewTripHandler(tripRepo, trips.NewSummarizer(vehicleRepo, cfg)),
+		Live:     handlers.NewLiveHandler(liveHub, cfg.LivePingInterval),
+		Event:    handlers.NewEventHandler(eventHub, cfg.EventKeepAlive),
+		APIKey:   handlers.NewAPIKeyHandler(apiKeyRepo, authenticator),
+		Auth:     authenticator,
+		Limits:   rateLimits,
+	})
 
 	// Handle graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -113,6 +270,11 @@ func main() {
 
 	<-quit
 	log.Println("Shutting down server...")
+
+	// Disconnect feed clients so their open streams don't hold up shutdown
+	liveHub.Close()
+	eventHub.Close()
+
 	if err := app.Shutdown(); err != nil {
 		log.Printf("Error during shutdown: %v", err)
 	}
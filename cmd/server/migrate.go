@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/database"
+	"github.com/fuadsyah/transjakarta_fleet_management/migrations"
+)
+
+const migrateUsage = `Usage: server migrate <command>
+
+Commands:
+  up            apply every pending migration
+  down [steps]  revert the last applied migration, or the last steps migrations
+  status        list migrations and when they were applied`
+
+// runMigrate handles the migrate subcommand
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migrations", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Reverted %d migrations", reverted)
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+
+		for _, mig := range status {
+			applied := "pending"
+			if mig.AppliedAt != nil {
+				applied = "applied " + time.Unix(*mig.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", mig.Version, mig.Name, applied)
+		}
+
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+}
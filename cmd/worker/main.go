@@ -33,7 +33,7 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
-	// Start consuming in a goroutine
+	// Start consuming in goroutines
 	go func() {
 		err := consumer.Consume(handleGeofenceEvent)
 		if err != nil {
@@ -41,6 +41,20 @@ func main() {
 		}
 	}()
 
+	go func() {
+		err := consumer.ConsumeRouteEvents(handleRouteEvent)
+		if err != nil {
+			log.Fatalf("Failed to consume route messages: %v", err)
+		}
+	}()
+
+	go func() {
+		err := consumer.ConsumeOverspeedEvents(handleOverspeedEvent)
+		if err != nil {
+			log.Fatalf("Failed to consume speed messages: %v", err)
+		}
+	}()
+
 	<-quit
 	log.Println("Shutting down worker...")
 }
@@ -49,7 +63,37 @@ func handleGeofenceEvent(event *models.GeofenceEvent) {
 	log.Printf("=== GEOFENCE ALERT ===")
 	log.Printf("Vehicle ID: %s", event.VehicleID)
 	log.Printf("Event: %s", event.Event)
+	log.Printf("Geofence: %s (%s)", event.GeofenceName, event.GeofenceID)
+	if event.DwellSeconds > 0 {
+		log.Printf("Dwell: %ds", event.DwellSeconds)
+	}
 	log.Printf("Location: lat=%f, lon=%f", event.Location.Latitude, event.Location.Longitude)
 	log.Printf("Timestamp: %d", event.Timestamp)
 	log.Printf("======================")
 }
+
+func handleRouteEvent(event *models.RouteEvent) {
+	log.Printf("=== ROUTE ALERT ===")
+	log.Printf("Vehicle ID: %s", event.VehicleID)
+	log.Printf("Event: %s", event.Event)
+	log.Printf("Route: %s (%s)", event.RouteName, event.RouteID)
+	log.Printf("Distance from route: %.0fm", event.DistanceMeters)
+	log.Printf("Duration: %ds", event.DurationSeconds)
+	log.Printf("Location: lat=%f, lon=%f", event.Location.Latitude, event.Location.Longitude)
+	log.Printf("Timestamp: %d", event.Timestamp)
+	log.Printf("===================")
+}
+
+func handleOverspeedEvent(event *models.OverspeedEvent) {
+	log.Printf("=== OVERSPEED ALERT ===")
+	log.Printf("Vehicle ID: %s", event.VehicleID)
+	log.Printf("Event: %s", event.Event)
+	log.Printf("Peak speed: %.1f km/h (limit %.0f km/h)", event.PeakSpeed, event.SpeedLimit)
+	if event.GeofenceID != "" {
+		log.Printf("Speed zone: %s (%s)", event.GeofenceName, event.GeofenceID)
+	}
+	log.Printf("Duration: %ds since %d", event.DurationSeconds, event.StartedAt)
+	log.Printf("Location: lat=%f, lon=%f", event.Location.Latitude, event.Location.Longitude)
+	log.Printf("Timestamp: %d", event.Timestamp)
+	log.Printf("=======================")
+}
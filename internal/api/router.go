@@ -1,36 +1,120 @@
 package api
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/auth"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/handlers"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/ratelimit"
 )
 
-// SetupRouter configures the Fiber app with routes and middleware
-func SetupRouter(vehicleHandler *handlers.VehicleHandler) *fiber.App {
+// corsAllowHeaders are the request headers cross-origin clients may send
+const corsAllowHeaders = "Origin, Content-Type, Accept, Authorization, X-API-Key, Last-Event-ID"
+
+// corsExposeHeaders are the response headers cross-origin clients may read
+const corsExposeHeaders = "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset"
+
+// Handlers groups the HTTP handlers served by the API
+type Handlers struct {
+	Health   *handlers.HealthHandler
+	Vehicle  *handlers.VehicleHandler
+	Geofence *handlers.GeofenceHandler
+	Route    *handlers.RouteHandler
+	Trip     *handlers.TripHandler
+	Live     *handlers.LiveHandler
+	Event    *handlers.EventHandler
+	APIKey   *handlers.APIKeyHandler
+	Auth     *auth.Authenticator
+	Limits   *ratelimit.Limits
+}
+
+// SetupRouter configures the Fiber app with routes and middleware. Every
+// route except the health check requires authentication; reads need the
+// viewer role, changes to routes and geofences the dispatcher role and
+// API key management the admin role.
+//
+// Requests are rate limited per client IP before authentication, then per
+// API key or JWT subject with the limit of their route group.
+func SetupRouter(cfg *config.Config, h *Handlers) *fiber.App {
 	app := fiber.New(fiber.Config{
-		AppName: "Fleet Management API",
+		AppName:     "Fleet Management API",
+		ProxyHeader: cfg.ProxyHeader,
 	})
 
 	// Middleware
 	app.Use(logger.New())
 	app.Use(recover.New())
-	app.Use(cors.New())
+	if origins := strings.Join(splitOrigins(cfg.CORSAllowedOrigins), ","); origins != "" {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins:  origins,
+			AllowHeaders:  corsAllowHeaders,
+			ExposeHeaders: corsExposeHeaders,
+		}))
+	}
 
 	// Health check endpoint
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "healthy",
-		})
-	})
+	app.Get("/health", h.Health.Health)
+
+	perIP := ratelimit.PerIP(h.Limits.IP)
+	authenticate := h.Auth.Authenticate
+	viewer := h.Auth.Require(models.RoleViewer)
+	dispatcher := h.Auth.Require(models.RoleDispatcher)
+	admin := h.Auth.Require(models.RoleAdmin)
+
+	defaultLimit := ratelimit.PerCaller(h.Limits.Default)
+	locationsLimit := ratelimit.PerCaller(h.Limits.Locations)
+	historyLimit := ratelimit.PerCaller(h.Limits.History)
+	streamsLimit := ratelimit.PerCaller(h.Limits.Streams)
 
 	// API routes
-	vehicles := app.Group("/vehicles")
-	vehicles.Get("/:vehicle_id/location", vehicleHandler.GetLatestLocation)
-	vehicles.Get("/:vehicle_id/history", vehicleHandler.GetLocationHistory)
+	vehicles := app.Group("/vehicles", perIP, authenticate, viewer)
+	vehicles.Get("/locations", locationsLimit, h.Vehicle.ListLocations)
+	vehicles.Get("/within", locationsLimit, h.Vehicle.ListWithin)
+	vehicles.Get("/nearby", locationsLimit, h.Vehicle.ListNearby)
+	vehicles.Get("/history", historyLimit, h.Vehicle.ExportHistory)
+	vehicles.Get("/:vehicle_id/location", locationsLimit, h.Vehicle.GetLatestLocation)
+	vehicles.Get("/:vehicle_id/history", historyLimit, h.Vehicle.GetLocationHistory)
+	vehicles.Get("/:vehicle_id/trips", historyLimit, h.Trip.ListTrips)
+	vehicles.Get("/:vehicle_id/summary", historyLimit, h.Trip.GetSummary)
+	vehicles.Get("/:vehicle_id/route", defaultLimit, h.Route.GetRoute)
+	vehicles.Put("/:vehicle_id/route", dispatcher, defaultLimit, h.Route.AssignRoute)
+	vehicles.Delete("/:vehicle_id/route", dispatcher, defaultLimit, h.Route.UnassignRoute)
+
+	geofences := app.Group("/geofences", perIP, authenticate, viewer, defaultLimit)
+	geofences.Post("/", dispatcher, h.Geofence.CreateGeofence)
+	geofences.Get("/", h.Geofence.ListGeofences)
+	geofences.Get("/:id", h.Geofence.GetGeofence)
+	geofences.Put("/:id", dispatcher, h.Geofence.UpdateGeofence)
+	geofences.Delete("/:id", dispatcher, h.Geofence.DeleteGeofence)
+
+	apiKeys := app.Group("/api-keys", perIP, authenticate, admin, defaultLimit)
+	apiKeys.Post("/", h.APIKey.CreateAPIKey)
+	apiKeys.Get("/", h.APIKey.ListAPIKeys)
+	apiKeys.Delete("/:id", h.APIKey.RevokeAPIKey)
+
+	// Real-time feeds, limited per connection attempt
+	ws := app.Group("/ws", perIP, authenticate, viewer, streamsLimit, h.Live.Upgrade)
+	ws.Get("/locations", h.Live.Locations())
+
+	app.Get("/events/stream", perIP, authenticate, viewer, streamsLimit, h.Event.Stream)
 
 	return app
 }
+
+// splitOrigins splits a comma-separated origin list, dropping empty items
+func splitOrigins(list string) []string {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodNone   = "none" // authentication disabled
+)
+
+// principalKey is the Fiber local holding the authenticated principal
+const principalKey = "auth_principal"
+
+// maxCachedKeys bounds the API key cache; it is emptied when full
+const maxCachedKeys = 10000
+
+// Principal is the caller a request was authenticated as
+type Principal struct {
+	ID     string // API key ID or JWT subject
+	Name   string
+	Role   string
+	Method string
+}
+
+// KeyStore looks up stored API keys
+type KeyStore interface {
+	GetAPIKeyByHash(keyHash string) (*models.APIKey, error)
+}
+
+// cachedKey is a cached API key lookup. A nil key records an unknown key
+// so repeated guesses don't each reach the database.
+type cachedKey struct {
+	key      *models.APIKey
+	cachedAt time.Time
+}
+
+// Authenticator authenticates requests with an API key or a JWT and
+// checks the caller's role. API key lookups are cached for a short time,
+// so a revoked key may keep working on other instances until its cache
+// entry expires.
+type Authenticator struct {
+	enabled  bool
+	store    KeyStore
+	jwt      *jwtVerifier
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedKey // by key hash
+}
+
+// NewAuthenticator creates an authenticator from the auth and JWT settings
+func NewAuthenticator(store KeyStore, cfg *config.Config) (*Authenticator, error) {
+	verifier, err := newJWTVerifier(cfg.JWTSecret, cfg.JWTPublicKeyFile, cfg.JWTIssuer, cfg.JWTAudience)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		enabled:  cfg.AuthEnabled,
+		store:    store,
+		jwt:      verifier,
+		cacheTTL: cfg.AuthKeyCacheTime,
+		cache:    make(map[string]cachedKey),
+	}, nil
+}
+
+// Authenticate is a middleware that rejects requests without valid
+// credentials. An API key is read from the X-API-Key header, and an API
+// key or JWT from an "Authorization: Bearer" header or, for WebSocket and
+// EventSource clients that can't set headers, the access_token query
+// parameter.
+func (a *Authenticator) Authenticate(c *fiber.Ctx) error {
+	if !a.enabled {
+		c.Locals(principalKey, &Principal{ID: "anonymous", Name: "anonymous", Role: models.RoleAdmin, Method: MethodNone})
+		return c.Next()
+	}
+
+	credential := c.Get("X-API-Key")
+	if credential == "" {
+		if header := c.Get(fiber.HeaderAuthorization); len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			credential = strings.TrimSpace(header[7:])
+		}
+	}
+	if credential == "" {
+		credential = c.Query("access_token")
+	}
+	if credential == "" {
+		return unauthorized(c, "missing credentials")
+	}
+
+	var principal *Principal
+	if strings.HasPrefix(credential, KeyPrefix) {
+		key, err := a.lookupKey(credential)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: "failed to verify api key",
+			})
+		}
+		if key == nil || !keyActive(key, time.Now().Unix()) {
+			return unauthorized(c, "invalid api key")
+		}
+		principal = &Principal{ID: key.ID, Name: key.Name, Role: key.Role, Method: MethodAPIKey}
+	} else {
+		if a.jwt == nil {
+			return unauthorized(c, "invalid credentials")
+		}
+		claims, err := a.jwt.verify(credential)
+		if err != nil {
+			return unauthorized(c, "invalid token")
+		}
+		principal = &Principal{ID: claims.Subject, Name: claims.Subject, Role: claims.Role, Method: MethodJWT}
+	}
+
+	c.Locals(principalKey, principal)
+	return c.Next()
+}
+
+// Require returns a middleware that only lets through principals with at
+// least the given role. It must run after Authenticate.
+func (a *Authenticator) Require(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := FromContext(c)
+		if principal == nil {
+			return unauthorized(c, "missing credentials")
+		}
+		if !HasRole(principal.Role, role) {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error: "requires " + role + " role",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Forget drops an API key from the cache so its revocation takes effect
+// on this instance immediately
+func (a *Authenticator) Forget(keyID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for hash, entry := range a.cache {
+		if entry.key != nil && entry.key.ID == keyID {
+			delete(a.cache, hash)
+		}
+	}
+}
+
+// FromContext returns the principal a request was authenticated as, or
+// nil when it wasn't authenticated
+func FromContext(c *fiber.Ctx) *Principal {
+	principal, _ := c.Locals(principalKey).(*Principal)
+	return principal
+}
+
+// lookupKey returns the stored API key for a plaintext key, or nil when
+// it is unknown
+func (a *Authenticator) lookupKey(plaintext string) (*models.APIKey, error) {
+	hash := HashKey(plaintext)
+	now := time.Now()
+
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if ok && now.Sub(entry.cachedAt) < a.cacheTTL {
+		return entry.key, nil
+	}
+
+	key, err := a.store.GetAPIKeyByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if len(a.cache) >= maxCachedKeys {
+		a.cache = make(map[string]cachedKey)
+	}
+	a.cache[hash] = cachedKey{key: key, cachedAt: now}
+	a.mu.Unlock()
+
+	return key, nil
+}
+
+// keyActive reports whether an API key is neither revoked nor expired
+func keyActive(key *models.APIKey, now int64) bool {
+	if key.RevokedAt != nil {
+		return false
+	}
+	return key.ExpiresAt == nil || *key.ExpiresAt > now
+}
+
+// unauthorized rejects a request without valid credentials
+func unauthorized(c *fiber.Ctx, msg string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+	return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{Error: msg})
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtLeeway is the clock skew tolerated when checking token times
+const jwtLeeway = 30 * time.Second
+
+// Claims are the JWT claims read by the API. The subject identifies the
+// caller and role is one of viewer, dispatcher or admin.
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// jwtVerifier checks JWT signatures against a shared secret or a public
+// key, along with the expiry, issuer and audience
+type jwtVerifier struct {
+	parser *jwt.Parser
+	key    interface{}
+}
+
+// newJWTVerifier creates a verifier for HMAC tokens signed with secret, or
+// for RSA, ECDSA or Ed25519 tokens signed with the private half of the PEM
+// public key in publicKeyFile. It returns nil when neither is configured.
+func newJWTVerifier(secret, publicKeyFile, issuer, audience string) (*jwtVerifier, error) {
+	if secret != "" && publicKeyFile != "" {
+		return nil, fmt.Errorf("only one of JWT_SECRET and JWT_PUBLIC_KEY_FILE can be set")
+	}
+
+	var key interface{}
+	var methods []string
+
+	switch {
+	case secret != "":
+		key = []byte(secret)
+		methods = []string{"HS256", "HS384", "HS512"}
+
+	case publicKeyFile != "":
+		data, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt public key: %w", err)
+		}
+
+		key, methods, err = parsePublicKey(data)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, nil
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &jwtVerifier{parser: jwt.NewParser(opts...), key: key}, nil
+}
+
+// verify parses a token and returns its claims when it is valid
+func (v *jwtVerifier) verify(token string) (*Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+	if !ValidRole(claims.Role) {
+		return nil, fmt.Errorf("token has unknown role %q", claims.Role)
+	}
+
+	return &claims, nil
+}
+
+// parsePublicKey parses a PEM public key and returns the signing methods
+// it can verify
+func parsePublicKey(data []byte) (interface{}, []string, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, []string{"ES256", "ES384", "ES512"}, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, []string{"EdDSA"}, nil
+	}
+
+	return nil, nil, fmt.Errorf("jwt public key must be a PEM encoded RSA, ECDSA or Ed25519 key")
+}
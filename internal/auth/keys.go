@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// KeyPrefix starts every API key so it can be told apart from a JWT
+const KeyPrefix = "fm_"
+
+// keyPrefixLength is how much of a key is stored in clear to identify it
+const keyPrefixLength = len(KeyPrefix) + 8
+
+// Role ranks, a role is granted everything a lower ranked role is
+var roleRank = map[string]int{
+	models.RoleViewer:     1,
+	models.RoleDispatcher: 2,
+	models.RoleAdmin:      3,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// HasRole reports whether a principal with role has at least the required
+// role
+func HasRole(role, required string) bool {
+	return ValidRole(role) && roleRank[role] >= roleRank[required]
+}
+
+// NewAPIKey generates a random API key. It returns the key to store, with
+// the plaintext key that is shown once, and the hash to store it by.
+func NewAPIKey(name, role string, expiresAt *int64) (*models.CreatedAPIKey, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	if !ValidRole(role) {
+		return nil, "", fmt.Errorf("role must be %s, %s or %s", models.RoleViewer, models.RoleDispatcher, models.RoleAdmin)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plaintext := KeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.CreatedAPIKey{
+		APIKey: models.APIKey{
+			ID:        uuid.NewString(),
+			Name:      name,
+			Prefix:    plaintext[:keyPrefixLength],
+			Role:      role,
+			ExpiresAt: expiresAt,
+		},
+		Key: plaintext,
+	}
+
+	return key, HashKey(plaintext), nil
+}
+
+// HashKey returns the hex SHA-256 hash an API key is stored by. Keys are
+// long random strings, so a fast unsalted hash is enough to keep a leaked
+// table from exposing them.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
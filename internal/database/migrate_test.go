@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/migrations"
+)
+
+func file(sql string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(sql)}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	source := fstest.MapFS{
+		"0010_add_index.up.sql":      file("CREATE INDEX"),
+		"0002_create_trips.down.sql": file("DROP TABLE trips"),
+		"0002_create_trips.up.sql":   file("CREATE TABLE trips"),
+		"0001_init.up.sql":           file("CREATE TABLE vehicle_locations"),
+		"0001_init.down.sql":         file("DROP TABLE vehicle_locations"),
+		"README.md":                  file("not a migration"),
+		"0003_Bad_Name.up.sql":       file("ignored"),
+		"0004_subdir.up.sql/x":       file("directories are skipped"),
+	}
+
+	got, err := loadMigrations(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE vehicle_locations", Down: "DROP TABLE vehicle_locations"},
+		{Version: 2, Name: "create_trips", Up: "CREATE TABLE trips", Down: "DROP TABLE trips"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != want[i] {
+			t.Errorf("migration %d: got %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := map[string]struct {
+		source  fstest.MapFS
+		wantErr string
+	}{
+		"down without up": {
+			source: fstest.MapFS{
+				"0001_init.up.sql":   file("CREATE TABLE a"),
+				"0002_init.down.sql": file("DROP TABLE b"),
+			},
+			wantErr: "0002_init has no up file",
+		},
+		"version reused": {
+			source: fstest.MapFS{
+				"0001_init.up.sql":      file("CREATE TABLE a"),
+				"0001_other.up.sql":     file("CREATE TABLE b"),
+				"0001_other.down.sql":   file("DROP TABLE b"),
+				"0001_init.down.sql":    file("DROP TABLE a"),
+				"0002_unrelated.up.sql": file("SELECT 1"),
+			},
+			wantErr: "version 1 is used by both",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadMigrations(tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrations checks the shipped migrations load, are numbered
+// without gaps and can all be reverted
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, mig := range got {
+		if mig.Version != int64(i+1) {
+			t.Errorf("migration %d has version %d, want %d", i, mig.Version, i+1)
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			t.Errorf("migration %04d_%s has no down file", mig.Version, mig.Name)
+		}
+	}
+}
@@ -0,0 +1,558 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+)
+
+const (
+	// PartitionDaily creates one vehicle_locations partition per UTC day
+	PartitionDaily = "daily"
+	// PartitionMonthly creates one vehicle_locations partition per UTC month
+	PartitionMonthly = "monthly"
+
+	partitionParent  = "vehicle_locations"
+	partitionDefault = "vehicle_locations_default"
+	partitionPrefix  = "vehicle_locations_p"
+
+	dailyLayout   = "20060102"
+	monthlyLayout = "200601"
+
+	// maxBackfillPartitions caps how many past partitions are created for
+	// rows already sitting in the default partition
+	maxBackfillPartitions = 400
+
+	// partitionLockKey is the advisory lock serialising partition changes
+	// between the server and the maintenance job
+	partitionLockKey = 7211001
+
+	secondsPerDay = 24 * 60 * 60
+)
+
+// partitionBoundPattern matches the bound expression of a range partition
+// of vehicle_locations as printed by pg_get_expr
+var partitionBoundPattern = regexp.MustCompile(`^FOR VALUES FROM \('?([^')]+)'?\) TO \('?([^')]+)'?\)$`)
+
+// span is a [from, to) range of unix timestamps
+type span struct {
+	from int64
+	to   int64
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// PartitionManager creates upcoming vehicle_locations partitions and
+// detaches or drops partitions that fall outside the retention window.
+// Every change holds a PostgreSQL advisory lock, so the server and the
+// maintenance job can both run it.
+type PartitionManager struct {
+	db        *sql.DB
+	interval  string
+	premake   int
+	retention time.Duration // 0 keeps every partition
+	detach    bool
+}
+
+// NewPartitionManager creates a new partition manager
+func NewPartitionManager(db *sql.DB, cfg *config.Config) (*PartitionManager, error) {
+	if cfg.PartitionInterval != PartitionDaily && cfg.PartitionInterval != PartitionMonthly {
+		return nil, fmt.Errorf("invalid partition interval %q: must be %s or %s",
+			cfg.PartitionInterval, PartitionDaily, PartitionMonthly)
+	}
+
+	return &PartitionManager{
+		db:        db,
+		interval:  cfg.PartitionInterval,
+		premake:   cfg.PartitionPremake,
+		retention: cfg.LocationRetention,
+		detach:    cfg.PartitionDetach,
+	}, nil
+}
+
+// EnsurePartitions creates every missing partition from the current period
+// up to premake periods ahead. Rows in the default partition that belong to
+// an earlier period still inside the retention window get a partition too,
+// so data migrated from the unpartitioned table is moved out of the default.
+//
+// Periods are compared against the bounds of the partitions already
+// attached rather than their names, so changing PARTITION_INTERVAL never
+// attaches an overlapping partition: covered periods are skipped and the
+// uncovered days of a partly covered period get daily partitions. A
+// partition that fails is logged and the rest are still created.
+func (m *PartitionManager) EnsurePartitions(now time.Time) error {
+	attached, err := attachedSpans(m.db)
+	if err != nil {
+		return err
+	}
+
+	from := m.periodStart(now)
+	to := from
+	for i := 0; i <= m.premake; i++ {
+		to = m.next(to)
+	}
+
+	oldest, err := m.oldestDefaultRow()
+	if err != nil {
+		return err
+	}
+	if oldest != nil {
+		start := m.periodStart(*oldest)
+		if m.retention > 0 {
+			if cutoff := m.periodStart(now.Add(-m.retention)); start.Before(cutoff) {
+				start = cutoff
+			}
+		}
+		for i := 0; i < maxBackfillPartitions && start.Before(from); i++ {
+			from = m.prev(from)
+		}
+	}
+
+	created, failed := 0, 0
+	for start := from; start.Before(to); start = m.next(start) {
+		period := span{from: start.Unix(), to: m.next(start).Unix()}
+
+		for _, gap := range uncovered(attached, period) {
+			if gap != period && (gap.from%secondsPerDay != 0 || gap.to%secondsPerDay != 0) {
+				log.Printf("Skipping vehicle_locations range %d - %d: not aligned to whole days", gap.from, gap.to)
+				continue
+			}
+
+			for _, part := range m.split(gap, period) {
+				ok, err := m.createPartition(part.name, part.from, part.to)
+				if err != nil {
+					log.Printf("Failed to create partition: %v", err)
+					failed++
+					continue
+				}
+				if ok {
+					created++
+				}
+			}
+		}
+	}
+
+	if created > 0 {
+		log.Printf("Created %d vehicle_locations partitions", created)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to create %d vehicle_locations partitions", failed)
+	}
+	return nil
+}
+
+// partition is a partition to create
+type partition struct {
+	name string
+	from time.Time
+	to   time.Time
+}
+
+// split returns the partitions that fill an uncovered gap of a period: one
+// partition for the whole period when none of it is covered, otherwise one
+// daily partition per day of the gap
+func (m *PartitionManager) split(gap, period span) []partition {
+	if gap == period {
+		start := time.Unix(period.from, 0).UTC()
+		return []partition{{name: m.partitionName(start), from: start, to: time.Unix(period.to, 0).UTC()}}
+	}
+
+	var parts []partition
+	for day := gap.from; day < gap.to; day += secondsPerDay {
+		start := time.Unix(day, 0).UTC()
+		parts = append(parts, partition{
+			name: partitionPrefix + start.Format(dailyLayout),
+			from: start,
+			to:   start.AddDate(0, 0, 1),
+		})
+	}
+	return parts
+}
+
+// EnforceRetention detaches or drops every partition that ends before the
+// retention window and deletes expired rows from the default partition.
+// It returns the number of partitions removed.
+func (m *PartitionManager) EnforceRetention(now time.Time) (int, error) {
+	if m.retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := now.Add(-m.retention).UTC()
+
+	existing, err := m.partitions()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for name := range existing {
+		start, end, ok := partitionRange(name)
+		if !ok || end.After(cutoff) {
+			continue
+		}
+
+		done, err := m.removePartition(name)
+		if err != nil {
+			return removed, err
+		}
+		if !done {
+			continue
+		}
+
+		if m.detach {
+			log.Printf("Detached partition %s (%s - %s)", name, start.Format(time.DateOnly), end.Format(time.DateOnly))
+		} else {
+			log.Printf("Dropped partition %s (%s - %s)", name, start.Format(time.DateOnly), end.Format(time.DateOnly))
+		}
+		removed++
+	}
+
+	result, err := m.db.Exec("DELETE FROM "+partitionDefault+" WHERE timestamp < $1", cutoff.Unix())
+	if err != nil {
+		return removed, fmt.Errorf("failed to delete expired default partition rows: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		log.Printf("Deleted %d expired rows from %s", rows, partitionDefault)
+	}
+
+	return removed, nil
+}
+
+// Run maintains partitions on every interval until the context is cancelled
+func (m *PartitionManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := m.EnsurePartitions(now); err != nil {
+				log.Printf("Failed to create partitions: %v", err)
+			}
+			if _, err := m.EnforceRetention(now); err != nil {
+				log.Printf("Failed to enforce location retention: %v", err)
+			}
+		}
+	}
+}
+
+// createPartition builds a partition as a standalone table, moves matching
+// rows out of the default partition and attaches it, all in one transaction.
+// Creating it directly with PARTITION OF would fail whenever the default
+// partition already holds rows in the range. It returns false when an
+// attached partition already overlaps the range.
+func (m *PartitionManager) createPartition(name string, from, to time.Time) (bool, error) {
+	tx, err := m.beginLocked()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Block inserts into the default partition so no row can slip into the
+	// range between the move and the attach
+	if _, err := tx.Exec("LOCK TABLE " + partitionDefault + " IN ACCESS EXCLUSIVE MODE"); err != nil {
+		return false, fmt.Errorf("failed to create partition %s: %w", name, err)
+	}
+
+	// Another process may have covered the range since the caller checked
+	attached, err := attachedSpans(tx)
+	if err != nil {
+		return false, err
+	}
+	r := span{from: from.Unix(), to: to.Unix()}
+	if gaps := uncovered(attached, r); len(gaps) != 1 || gaps[0] != r {
+		return false, nil
+	}
+
+	table := pq.QuoteIdentifier(name)
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)",
+			table, partitionParent),
+		fmt.Sprintf(`WITH moved AS (
+			DELETE FROM %s WHERE timestamp >= %d AND timestamp < %d RETURNING *
+		)
+		INSERT INTO %s SELECT * FROM moved`,
+			partitionDefault, from.Unix(), to.Unix(), table),
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM (%d) TO (%d)",
+			partitionParent, table, from.Unix(), to.Unix()),
+	}
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return false, fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit partition %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// removePartition detaches or drops a partition. It returns false when
+// another process already removed it.
+func (m *PartitionManager) removePartition(name string) (bool, error) {
+	tx, err := m.beginLocked()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	attached, err := isAttached(tx, name)
+	if err != nil || !attached {
+		return false, err
+	}
+
+	table := pq.QuoteIdentifier(name)
+	if m.detach {
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", partitionParent, table))
+	} else {
+		_, err = tx.Exec("DROP TABLE IF EXISTS " + table)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remove partition %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit partition %s removal: %w", name, err)
+	}
+	return true, nil
+}
+
+// beginLocked begins a transaction holding the partition advisory lock, so
+// the server and the maintenance job never change partitions concurrently.
+// The lock is released when the transaction ends.
+func (m *PartitionManager) beginLocked() (*sql.Tx, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", partitionLockKey); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to lock partitions: %w", err)
+	}
+	return tx, nil
+}
+
+// isAttached reports whether a table is currently a partition of
+// vehicle_locations
+func isAttached(tx *sql.Tx, name string) (bool, error) {
+	var attached bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM pg_inherits i
+			JOIN pg_class c ON c.oid = i.inhrelid
+			JOIN pg_class p ON p.oid = i.inhparent
+			WHERE p.relname = $1 AND c.relname = $2
+		)
+	`, partitionParent, name).Scan(&attached)
+	if err != nil {
+		return false, fmt.Errorf("failed to check partition %s: %w", name, err)
+	}
+	return attached, nil
+}
+
+// partitions returns the names of the partitions attached to vehicle_locations
+func (m *PartitionManager) partitions() (map[string]bool, error) {
+	rows, err := m.db.Query(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = $1
+	`, partitionParent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		names[name] = true
+	}
+
+	return names, rows.Err()
+}
+
+// attachedSpans returns the ranges of the partitions attached to
+// vehicle_locations, ordered by start. The default partition is left out.
+func attachedSpans(q querier) ([]span, error) {
+	rows, err := q.Query(`
+		SELECT c.relname, pg_get_expr(c.relpartbound, c.oid)
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = $1
+	`, partitionParent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partition bounds: %w", err)
+	}
+	defer rows.Close()
+
+	var spans []span
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, fmt.Errorf("failed to scan partition bounds: %w", err)
+		}
+		if bound == "DEFAULT" {
+			continue
+		}
+
+		sp, ok := parseBound(bound)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse bounds of partition %s: %q", name, bound)
+		}
+		spans = append(spans, sp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].from < spans[j].from
+	})
+	return spans, nil
+}
+
+// parseBound parses a partition bound expression such as
+// FOR VALUES FROM ('1715000000') TO ('1715086400')
+func parseBound(bound string) (span, bool) {
+	match := partitionBoundPattern.FindStringSubmatch(bound)
+	if match == nil {
+		return span{}, false
+	}
+
+	from, ok := parseBoundValue(match[1])
+	if !ok {
+		return span{}, false
+	}
+	to, ok := parseBoundValue(match[2])
+	if !ok {
+		return span{}, false
+	}
+	return span{from: from, to: to}, true
+}
+
+// parseBoundValue parses one side of a partition bound
+func parseBoundValue(value string) (int64, bool) {
+	switch value {
+	case "MINVALUE":
+		return math.MinInt64, true
+	case "MAXVALUE":
+		return math.MaxInt64, true
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	return n, err == nil
+}
+
+// uncovered returns the parts of r not covered by any of the spans, which
+// must be ordered by start
+func uncovered(spans []span, r span) []span {
+	var gaps []span
+	cur := r.from
+	for _, sp := range spans {
+		if sp.to <= cur || sp.from >= r.to {
+			continue
+		}
+		if sp.from > cur {
+			gaps = append(gaps, span{from: cur, to: sp.from})
+		}
+		cur = sp.to
+		if cur >= r.to {
+			return gaps
+		}
+	}
+	return append(gaps, span{from: cur, to: r.to})
+}
+
+// oldestDefaultRow returns the time of the oldest row in the default
+// partition, or nil when it is empty
+func (m *PartitionManager) oldestDefaultRow() (*time.Time, error) {
+	var ts sql.NullInt64
+	if err := m.db.QueryRow("SELECT MIN(timestamp) FROM " + partitionDefault).Scan(&ts); err != nil {
+		return nil, fmt.Errorf("failed to query default partition: %w", err)
+	}
+	if !ts.Valid {
+		return nil, nil
+	}
+
+	t := time.Unix(ts.Int64, 0).UTC()
+	return &t, nil
+}
+
+// periodStart returns the start of the partition period containing t
+func (m *PartitionManager) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if m.interval == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the period following the one starting at t
+func (m *PartitionManager) next(t time.Time) time.Time {
+	if m.interval == PartitionMonthly {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// prev returns the start of the period preceding the one starting at t
+func (m *PartitionManager) prev(t time.Time) time.Time {
+	if m.interval == PartitionMonthly {
+		return t.AddDate(0, -1, 0)
+	}
+	return t.AddDate(0, 0, -1)
+}
+
+// partitionName returns the table name of the partition starting at t
+func (m *PartitionManager) partitionName(t time.Time) string {
+	if m.interval == PartitionMonthly {
+		return partitionPrefix + t.Format(monthlyLayout)
+	}
+	return partitionPrefix + t.Format(dailyLayout)
+}
+
+// partitionRange parses the period covered by a partition from its name.
+// Both daily and monthly names are recognised so partitions created before
+// the interval was changed still age out.
+func partitionRange(name string) (time.Time, time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if start, err := time.Parse(dailyLayout, suffix); err == nil && len(suffix) == len(dailyLayout) {
+		return start, start.AddDate(0, 0, 1), true
+	}
+	if start, err := time.Parse(monthlyLayout, suffix); err == nil && len(suffix) == len(monthlyLayout) {
+		return start, start.AddDate(0, 1, 0), true
+	}
+
+	return time.Time{}, time.Time{}, false
+}
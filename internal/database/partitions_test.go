@@ -0,0 +1,159 @@
+package database
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPartitionRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		ok         bool
+	}{
+		{"vehicle_locations_p20240229", day(2024, 2, 29), day(2024, 3, 1), true},
+		{"vehicle_locations_p20241231", day(2024, 12, 31), day(2025, 1, 1), true},
+		{"vehicle_locations_p202412", day(2024, 12, 1), day(2025, 1, 1), true},
+		{"vehicle_locations_p202402", day(2024, 2, 1), day(2024, 3, 1), true},
+		{"vehicle_locations_default", time.Time{}, time.Time{}, false},
+		{"vehicle_locations_p2024", time.Time{}, time.Time{}, false},
+		{"vehicle_locations_p20240230", time.Time{}, time.Time{}, false},
+		{"trips_p20240101", time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		start, end, ok := partitionRange(tt.name)
+		if ok != tt.ok || !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("partitionRange(%q) = %s, %s, %v; want %s, %s, %v",
+				tt.name, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestPartitionPeriods(t *testing.T) {
+	// 23:30 on Jan 31 in Jakarta is still Jan 31 in UTC, 00:30 on Feb 1 is not
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	daily := &PartitionManager{interval: PartitionDaily}
+	monthly := &PartitionManager{interval: PartitionMonthly}
+
+	tests := []struct {
+		m     *PartitionManager
+		at    time.Time
+		start time.Time
+		next  time.Time
+		prev  time.Time
+		name  string
+	}{
+		{daily, time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC), day(2024, 1, 31), day(2024, 2, 1), day(2024, 1, 30), "vehicle_locations_p20240131"},
+		{daily, time.Date(2024, 2, 1, 0, 30, 0, 0, jakarta), day(2024, 1, 31), day(2024, 2, 1), day(2024, 1, 30), "vehicle_locations_p20240131"},
+		{daily, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), day(2024, 3, 1), day(2024, 3, 2), day(2024, 2, 29), "vehicle_locations_p20240301"},
+		{monthly, time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC), day(2024, 1, 1), day(2024, 2, 1), day(2023, 12, 1), "vehicle_locations_p202401"},
+		{monthly, time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC), day(2024, 12, 1), day(2025, 1, 1), day(2024, 11, 1), "vehicle_locations_p202412"},
+	}
+
+	for _, tt := range tests {
+		start := tt.m.periodStart(tt.at)
+		if !start.Equal(tt.start) {
+			t.Errorf("%s periodStart(%s) = %s, want %s", tt.m.interval, tt.at, start, tt.start)
+			continue
+		}
+		if next := tt.m.next(start); !next.Equal(tt.next) {
+			t.Errorf("%s next(%s) = %s, want %s", tt.m.interval, start, next, tt.next)
+		}
+		if prev := tt.m.prev(start); !prev.Equal(tt.prev) {
+			t.Errorf("%s prev(%s) = %s, want %s", tt.m.interval, start, prev, tt.prev)
+		}
+		if name := tt.m.partitionName(start); name != tt.name {
+			t.Errorf("%s partitionName(%s) = %q, want %q", tt.m.interval, start, name, tt.name)
+		}
+
+		// The name must parse back to the same period
+		if from, to, ok := partitionRange(tt.name); !ok || !from.Equal(tt.start) || !to.Equal(tt.next) {
+			t.Errorf("partitionRange(%q) = %s, %s, %v; want %s, %s", tt.name, from, to, ok, tt.start, tt.next)
+		}
+	}
+}
+
+func TestParseBound(t *testing.T) {
+	tests := []struct {
+		bound string
+		want  span
+		ok    bool
+	}{
+		{"FOR VALUES FROM ('1704067200') TO ('1704153600')", span{1704067200, 1704153600}, true},
+		{"FOR VALUES FROM (1704067200) TO (1704153600)", span{1704067200, 1704153600}, true},
+		{"FOR VALUES FROM (MINVALUE) TO ('0')", span{math.MinInt64, 0}, true},
+		{"FOR VALUES FROM ('1704067200') TO (MAXVALUE)", span{1704067200, math.MaxInt64}, true},
+		{"FOR VALUES IN ('a')", span{}, false},
+		{"FOR VALUES FROM ('abc') TO ('1')", span{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseBound(tt.bound)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseBound(%q) = %v, %v; want %v, %v", tt.bound, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUncovered(t *testing.T) {
+	attached := []span{{10, 20}, {20, 30}, {50, 60}}
+
+	tests := []struct {
+		r    span
+		want []span
+	}{
+		{span{0, 10}, []span{{0, 10}}},
+		{span{10, 30}, nil},
+		{span{15, 25}, nil},
+		{span{0, 100}, []span{{0, 10}, {30, 50}, {60, 100}}},
+		{span{25, 55}, []span{{30, 50}}},
+		{span{60, 70}, []span{{60, 70}}},
+	}
+
+	for _, tt := range tests {
+		if got := uncovered(attached, tt.r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("uncovered(%v) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureAfterIntervalChange(t *testing.T) {
+	// Daily partitions for Jan 30 - Feb 2 exist when the interval is
+	// switched to monthly: February only gets its uncovered days, as daily
+	// partitions, and January is already covered through Jan 31.
+	var attached []span
+	for d := day(2024, 1, 30); d.Before(day(2024, 2, 3)); d = d.AddDate(0, 0, 1) {
+		attached = append(attached, span{d.Unix(), d.AddDate(0, 0, 1).Unix()})
+	}
+
+	m := &PartitionManager{interval: PartitionMonthly}
+	feb := span{day(2024, 2, 1).Unix(), day(2024, 3, 1).Unix()}
+
+	gaps := uncovered(attached, feb)
+	if len(gaps) != 1 || gaps[0] != (span{day(2024, 2, 3).Unix(), feb.to}) {
+		t.Fatalf("got gaps %v, want Feb 3 - Mar 1", gaps)
+	}
+
+	parts := m.split(gaps[0], feb)
+	if len(parts) != 27 {
+		t.Fatalf("got %d partitions, want one per day from Feb 3 to Feb 29", len(parts))
+	}
+	if first, last := parts[0], parts[len(parts)-1]; first.name != "vehicle_locations_p20240203" ||
+		last.name != "vehicle_locations_p20240229" || !last.to.Equal(day(2024, 3, 1)) {
+		t.Errorf("got partitions %s to %s ending %s", first.name, last.name, last.to)
+	}
+
+	// March is untouched, so it gets a single monthly partition
+	mar := span{day(2024, 3, 1).Unix(), day(2024, 4, 1).Unix()}
+	if parts := m.split(mar, mar); len(parts) != 1 || parts[0].name != "vehicle_locations_p202403" {
+		t.Errorf("got partitions %v for an uncovered month", parts)
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -8,6 +9,7 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/migrations"
 )
 
 // Connect establishes connection to PostgreSQL database
@@ -34,27 +36,18 @@ func Connect(cfg *config.Config) (*sql.DB, error) {
 	return db, nil
 }
 
-// InitSchema creates the required tables if they don't exist
-func InitSchema(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS vehicle_locations (
-		id SERIAL PRIMARY KEY,
-		vehicle_id VARCHAR(50) NOT NULL,
-		latitude DOUBLE PRECISION NOT NULL,
-		longitude DOUBLE PRECISION NOT NULL,
-		timestamp BIGINT NOT NULL
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_vehicle_locations_vehicle_id ON vehicle_locations(vehicle_id);
-	CREATE INDEX IF NOT EXISTS idx_vehicle_locations_timestamp ON vehicle_locations(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_vehicle_locations_vehicle_timestamp ON vehicle_locations(vehicle_id, timestamp DESC);
-	`
-
-	_, err := db.Exec(query)
+// Migrate applies every pending schema migration
+func Migrate(db *sql.DB) error {
+	migrator, err := NewMigrator(db, migrations.FS)
 	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+		return err
 	}
 
-	log.Println("Database schema initialized successfully")
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Database schema up to date (%d migrations applied)", applied)
 	return nil
 }
@@ -1,32 +1,220 @@
 package geofence
 
 import (
+	"log"
 	"math"
+	"sync"
+	"time"
 
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 )
 
-// Checker handles geofence checking logic
+// Fence is a named geofence backed by a circle or polygon shape
+type Fence struct {
+	ID         string
+	Name       string
+	Shape      Shape
+	ExpiresAt  int64   // unix timestamp, 0 for permanent fences
+	SpeedLimit float64 // in km/h, 0 when the fence is not a speed zone
+	Hysteresis Hysteresis
+}
+
+// Hysteresis controls how a fence debounces GPS jitter at its boundary
+type Hysteresis struct {
+	ExitBuffer float64 // meters outside the fence before a vehicle counts as outside
+	MinPings   int     // consecutive pings needed to confirm an entry or exit
+	MinSeconds int64   // seconds an entry or exit must persist before it counts
+}
+
+// holds reports whether a vehicle already inside the fence is still
+// considered inside, allowing for the exit buffer
+func (f *Fence) holds(lat, lon float64) bool {
+	if f.Hysteresis.ExitBuffer <= 0 {
+		return f.Shape.Contains(lat, lon)
+	}
+	return f.Shape.Distance(lat, lon) <= f.Hysteresis.ExitBuffer
+}
+
+// activeAt reports whether the fence has not expired at the timestamp
+func (f *Fence) activeAt(timestamp int64) bool {
+	return f.ExpiresAt == 0 || timestamp < f.ExpiresAt
+}
+
+// Checker handles geofence checking logic. Fences are kept in a grid
+// index so lookups stay fast with thousands of fences registered.
+//
+// Fences loaded at construction (from the geofence file or the default
+// configuration) are static; all other fences are dynamic and can be
+// replaced at runtime with Sync.
 type Checker struct {
-	cfg *config.Config
+	defaults Hysteresis
+
+	mu         sync.RWMutex
+	fences     map[string]*Fence
+	static     map[string]*Fence
+	index      *gridIndex
+	generation uint64 // bumped by every AddFence and RemoveFence
+}
+
+// NewChecker creates a new geofence checker. Fences are loaded from the
+// configured GeoJSON file, or the single default circular fence is used
+// when no file is configured.
+func NewChecker(cfg *config.Config) (*Checker, error) {
+	c := &Checker{
+		defaults: Hysteresis{
+			ExitBuffer: cfg.GeofenceExitBuffer,
+			MinPings:   cfg.GeofenceMinPings,
+			MinSeconds: int64(cfg.GeofenceMinTime / time.Second),
+		},
+		fences: make(map[string]*Fence),
+		static: make(map[string]*Fence),
+		index:  newGridIndex(),
+	}
+
+	if cfg.GeofenceFile != "" {
+		fences, err := LoadFile(cfg.GeofenceFile, c.defaults)
+		if err != nil {
+			return nil, err
+		}
+		for _, fence := range fences {
+			c.addStatic(fence)
+		}
+		log.Printf("Loaded %d geofences from %s", len(fences), cfg.GeofenceFile)
+		return c, nil
+	}
+
+	c.addStatic(&Fence{
+		ID:   cfg.GeofenceID,
+		Name: cfg.GeofenceName,
+		Shape: &Circle{
+			Center: models.Location{
+				Latitude:  cfg.GeofenceLatitude,
+				Longitude: cfg.GeofenceLongitude,
+			},
+			Radius: cfg.GeofenceRadius,
+		},
+		Hysteresis: c.defaults,
+	})
+	return c, nil
+}
+
+// addStatic registers a fence that Sync will never remove
+func (c *Checker) addStatic(fence *Fence) {
+	c.static[fence.ID] = fence
+	c.AddFence(fence)
 }
 
-// NewChecker creates a new geofence checker
-func NewChecker(cfg *config.Config) *Checker {
-	return &Checker{cfg: cfg}
+// HasStaticFence reports whether the ID belongs to a static fence
+func (c *Checker) HasStaticFence(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.static[id]
+	return ok
 }
 
-// IsInsideGeofence checks if a location is within the geofence radius
-func (c *Checker) IsInsideGeofence(loc *models.VehicleLocation) bool {
-	distance := haversineDistance(
-		c.cfg.GeofenceLatitude,
-		c.cfg.GeofenceLongitude,
-		loc.Latitude,
-		loc.Longitude,
-	)
+// Generation returns a number that changes whenever a fence is added or
+// removed outside of Sync
+func (c *Checker) Generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.generation
+}
+
+// Sync replaces every dynamic fence with the given fences, which must have
+// been loaded after Generation returned generation. Static fences are kept
+// unless a given fence has the same ID. It changes nothing and returns
+// false when a fence was added or removed since, because the fences may
+// predate that change.
+func (c *Checker) Sync(fences []*Fence, generation uint64) bool {
+	byID := make(map[string]*Fence, len(c.static)+len(fences))
+	for id, fence := range c.static {
+		byID[id] = fence
+	}
+	for _, fence := range fences {
+		byID[fence.ID] = fence
+	}
+
+	index := newGridIndex()
+	for _, fence := range byID {
+		index.insert(fence)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return false
+	}
+	c.fences = byID
+	c.index = index
+	return true
+}
+
+// AddFence registers a fence with the checker, replacing any existing
+// fence with the same ID
+func (c *Checker) AddFence(fence *Fence) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.fences[fence.ID]; ok {
+		c.index.remove(existing)
+	}
+	c.fences[fence.ID] = fence
+	c.index.insert(fence)
+	c.generation++
+}
+
+// RemoveFence unregisters the fence with the given ID
+func (c *Checker) RemoveFence(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.fences[id]; ok {
+		c.index.remove(existing)
+		delete(c.fences, id)
+	}
+	c.generation++
+}
+
+// Fence returns the registered fence with the given ID, or nil
+func (c *Checker) Fence(id string) *Fence {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.fences[id]
+}
+
+// Len returns the number of registered fences
+func (c *Checker) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.fences)
+}
+
+// IsInsideGeofence returns every fence that contains the location
+func (c *Checker) IsInsideGeofence(loc *models.VehicleLocation) []*Fence {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var matches []*Fence
+	for _, fence := range c.index.candidates(loc.Latitude, loc.Longitude) {
+		if fence.activeAt(loc.Timestamp) &&
+			fence.Shape.Bounds().Contains(loc.Latitude, loc.Longitude) &&
+			fence.Shape.Contains(loc.Latitude, loc.Longitude) {
+			matches = append(matches, fence)
+		}
+	}
+
+	return matches
+}
 
-	return distance <= c.cfg.GeofenceRadius
+// Distance returns the great-circle distance between two points in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	return haversineDistance(lat1, lon1, lat2, lon2)
 }
 
 // haversineDistance calculates the distance between two points in meters
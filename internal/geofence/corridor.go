@@ -0,0 +1,72 @@
+package geofence
+
+import (
+	"math"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Corridor is a fence defined by one or more polylines and a buffer width,
+// such as a BRT corridor. A point is inside when it lies within Buffer
+// meters of any segment of the paths.
+type Corridor struct {
+	Paths  [][]models.Location
+	Buffer float64 // in meters, measured on each side of the path
+}
+
+// DistanceToPath returns the distance in meters from the point to the
+// nearest segment of the corridor paths
+func (c *Corridor) DistanceToPath(lat, lon float64) float64 {
+	best := math.Inf(1)
+	for _, path := range c.Paths {
+		if len(path) == 1 {
+			best = math.Min(best, haversineDistance(lat, lon, path[0].Latitude, path[0].Longitude))
+			continue
+		}
+		for i := 1; i < len(path); i++ {
+			best = math.Min(best, distanceToSegment(lat, lon, path[i-1], path[i]))
+		}
+	}
+	return best
+}
+
+// Contains reports whether the point is within the corridor buffer
+func (c *Corridor) Contains(lat, lon float64) bool {
+	return c.DistanceToPath(lat, lon) <= c.Buffer
+}
+
+// Distance returns how far the point lies outside the corridor buffer
+func (c *Corridor) Distance(lat, lon float64) float64 {
+	return math.Max(0, c.DistanceToPath(lat, lon)-c.Buffer)
+}
+
+// Bounds returns the bounding box of the paths grown by the buffer
+func (c *Corridor) Bounds() Bounds {
+	b := emptyBounds()
+	for _, path := range c.Paths {
+		for _, pt := range path {
+			b.extend(pt.Latitude, pt.Longitude)
+		}
+	}
+
+	dLat := c.Buffer / metersPerDegree
+	minLat := b.MinLat - dLat
+	maxLat := b.MaxLat + dLat
+
+	if minLat <= -90 || maxLat >= 90 {
+		return Bounds{MinLat: math.Max(minLat, -90), MinLon: -180, MaxLat: math.Min(maxLat, 90), MaxLon: 180}
+	}
+
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat))
+	dLon := c.Buffer / (metersPerDegree * math.Cos(widest*math.Pi/180))
+	if dLon >= 180 {
+		return Bounds{MinLat: minLat, MinLon: -180, MaxLat: maxLat, MaxLon: 180}
+	}
+
+	return Bounds{
+		MinLat: minLat,
+		MinLon: b.MinLon - dLon,
+		MaxLat: maxLat,
+		MaxLon: b.MaxLon + dLon,
+	}
+}
@@ -0,0 +1,183 @@
+package geofence
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// AssignmentSource provides the route assigned to each vehicle
+type AssignmentSource interface {
+	ListAssignments() ([]models.RouteAssignment, error)
+}
+
+// deviationState tracks how far one vehicle is from its assigned route
+type deviationState struct {
+	routeID       string
+	lastTimestamp int64
+	farSince      int64 // first ping beyond the distance threshold, 0 while on route
+	offRoute      bool
+}
+
+// DeviationDetector raises off_route events when a vehicle stays farther
+// than the configured distance from its assigned corridor for the
+// configured time, and back_on_route events when it returns
+type DeviationDetector struct {
+	checker     *Checker
+	source      AssignmentSource
+	maxDistance float64 // in meters
+	minDuration int64   // in seconds
+
+	mu          sync.Mutex
+	assignments map[string]string // vehicle ID -> route ID
+	states      map[string]*deviationState
+}
+
+// NewDeviationDetector creates a new route deviation detector
+func NewDeviationDetector(checker *Checker, source AssignmentSource, maxDistance float64, minDuration time.Duration) *DeviationDetector {
+	return &DeviationDetector{
+		checker:     checker,
+		source:      source,
+		maxDistance: maxDistance,
+		minDuration: int64(minDuration / time.Second),
+		assignments: make(map[string]string),
+		states:      make(map[string]*deviationState),
+	}
+}
+
+// Assign sets the route of a vehicle. An empty route ID removes the
+// assignment.
+func (d *DeviationDetector) Assign(vehicleID, routeID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if routeID == "" {
+		delete(d.assignments, vehicleID)
+		return
+	}
+	d.assignments[vehicleID] = routeID
+}
+
+// RouteOf returns the route assigned to a vehicle, or an empty string
+func (d *DeviationDetector) RouteOf(vehicleID string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.assignments[vehicleID]
+}
+
+// Sync loads every route assignment from the source
+func (d *DeviationDetector) Sync() error {
+	list, err := d.source.ListAssignments()
+	if err != nil {
+		return err
+	}
+
+	assignments := make(map[string]string, len(list))
+	for _, a := range list {
+		assignments[a.VehicleID] = a.RouteID
+	}
+
+	d.mu.Lock()
+	d.assignments = assignments
+	d.mu.Unlock()
+	return nil
+}
+
+// Run syncs route assignments on every interval until the context is
+// cancelled
+func (d *DeviationDetector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Sync(); err != nil {
+				log.Printf("Failed to sync route assignments: %v", err)
+			}
+		}
+	}
+}
+
+// Process checks a location against the vehicle's assigned route and
+// returns an event when the vehicle goes off route or comes back. It
+// returns nil for vehicles without a route or whose route is not a
+// corridor geofence.
+func (d *DeviationDetector) Process(loc *models.VehicleLocation) *models.RouteEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	routeID, ok := d.assignments[loc.VehicleID]
+	if !ok {
+		delete(d.states, loc.VehicleID)
+		return nil
+	}
+
+	fence := d.checker.Fence(routeID)
+	if fence == nil {
+		return nil
+	}
+	corridor, ok := fence.Shape.(*Corridor)
+	if !ok {
+		return nil
+	}
+
+	state, ok := d.states[loc.VehicleID]
+	if !ok || state.routeID != routeID {
+		state = &deviationState{routeID: routeID}
+		d.states[loc.VehicleID] = state
+	}
+
+	if loc.Timestamp < state.lastTimestamp {
+		return nil
+	}
+	state.lastTimestamp = loc.Timestamp
+
+	distance := corridor.DistanceToPath(loc.Latitude, loc.Longitude)
+
+	if distance <= d.maxDistance {
+		farSince, wasOffRoute := state.farSince, state.offRoute
+		state.farSince = 0
+		state.offRoute = false
+
+		if wasOffRoute {
+			return newRouteEvent(models.EventBackOnRoute, fence, loc, distance, loc.Timestamp-farSince)
+		}
+		return nil
+	}
+
+	if state.farSince == 0 {
+		state.farSince = loc.Timestamp
+	}
+
+	duration := loc.Timestamp - state.farSince
+	if !state.offRoute && duration >= d.minDuration {
+		state.offRoute = true
+		return newRouteEvent(models.EventOffRoute, fence, loc, distance, duration)
+	}
+
+	return nil
+}
+
+// newRouteEvent builds a route event for a vehicle location
+func newRouteEvent(event string, route *Fence, loc *models.VehicleLocation, distance float64, duration int64) *models.RouteEvent {
+	return &models.RouteEvent{
+		VehicleID:       loc.VehicleID,
+		Event:           event,
+		RouteID:         route.ID,
+		RouteName:       route.Name,
+		DistanceMeters:  distance,
+		DurationSeconds: duration,
+		Location: models.Location{
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+		},
+		Timestamp: loc.Timestamp,
+	}
+}
@@ -0,0 +1,50 @@
+package geofence
+
+import (
+	"math"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// DistanceToSegment returns the distance in meters from a point to the
+// closest point of the segment a-b
+func DistanceToSegment(lat, lon float64, a, b models.Location) float64 {
+	return distanceToSegment(lat, lon, a, b)
+}
+
+// distanceToSegment returns the distance in meters from a point to the
+// closest point of the segment a-b. The closest point is found on a local
+// equirectangular projection around the point, which is accurate for the
+// short segments that make up fence edges, and the final distance is
+// measured with haversineDistance.
+func distanceToSegment(lat, lon float64, a, b models.Location) float64 {
+	cosLat := math.Cos(lat * math.Pi / 180)
+
+	// Project both endpoints to meters relative to the point
+	ax := (a.Longitude - lon) * cosLat * metersPerDegree
+	ay := (a.Latitude - lat) * metersPerDegree
+	bx := (b.Longitude - lon) * cosLat * metersPerDegree
+	by := (b.Latitude - lat) * metersPerDegree
+
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		// Parameter of the projection of the origin onto the segment
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+	}
+
+	closestLat := a.Latitude + t*(b.Latitude-a.Latitude)
+	closestLon := a.Longitude + t*(b.Longitude-a.Longitude)
+
+	return haversineDistance(lat, lon, closestLat, closestLon)
+}
+
+// distanceToRing returns the distance in meters from a point to the
+// closest edge of a ring
+func distanceToRing(ring []models.Location, lat, lon float64) float64 {
+	best := math.Inf(1)
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		best = math.Min(best, distanceToSegment(lat, lon, ring[j], ring[i]))
+	}
+	return best
+}
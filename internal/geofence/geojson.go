@@ -0,0 +1,259 @@
+package geofence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Geometry is a GeoJSON geometry object
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// featureCollection is a GeoJSON FeatureCollection of fences
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// feature is a GeoJSON Feature describing one fence
+type feature struct {
+	Type       string            `json:"type"`
+	Geometry   Geometry          `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+// featureProperties holds the fence attributes of a feature
+type featureProperties struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Radius     float64  `json:"radius"`      // in meters, required for Point geometries
+	Buffer     float64  `json:"buffer"`      // in meters, required for LineString geometries
+	SpeedLimit float64  `json:"speed_limit"` // in km/h
+	ExitBuffer *float64 `json:"exit_buffer"`
+	MinPings   *int     `json:"min_pings"`
+	MinSeconds *int64   `json:"min_seconds"`
+}
+
+// LoadFile reads fences from a GeoJSON FeatureCollection file. Fences
+// without hysteresis properties use the given defaults.
+func LoadFile(path string, defaults Hysteresis) ([]*Fence, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geofence file: %w", err)
+	}
+
+	var fc featureCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to parse geofence file: %w", err)
+	}
+
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("geofence file must be a FeatureCollection, got %q", fc.Type)
+	}
+
+	fences := make([]*Fence, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		if f.Properties.ID == "" {
+			return nil, fmt.Errorf("feature %d: id property is required", i)
+		}
+
+		shape, err := ParseGeometry(f.Geometry, f.Properties.Radius, f.Properties.Buffer)
+		if err != nil {
+			return nil, fmt.Errorf("feature %s: %w", f.Properties.ID, err)
+		}
+
+		fences = append(fences, &Fence{
+			ID:         f.Properties.ID,
+			Name:       f.Properties.Name,
+			Shape:      shape,
+			SpeedLimit: f.Properties.SpeedLimit,
+			Hysteresis: resolveHysteresis(defaults, f.Properties.ExitBuffer, f.Properties.MinPings, f.Properties.MinSeconds),
+		})
+	}
+
+	return fences, nil
+}
+
+// FenceFromModel converts a stored geofence into a fence. Hysteresis
+// settings missing from the geofence are taken from the checker defaults.
+func (c *Checker) FenceFromModel(g *models.Geofence) (*Fence, error) {
+	var geometry Geometry
+	if err := json.Unmarshal(g.Geometry, &geometry); err != nil {
+		return nil, fmt.Errorf("invalid geometry: %w", err)
+	}
+
+	shape, err := ParseGeometry(geometry, g.Radius, g.Buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	fence := &Fence{
+		ID:         g.ID,
+		Name:       g.Name,
+		Shape:      shape,
+		SpeedLimit: g.SpeedLimit,
+		Hysteresis: resolveHysteresis(c.defaults, g.ExitBuffer, g.MinPings, g.MinSeconds),
+	}
+	if g.ExpiresAt != nil {
+		fence.ExpiresAt = *g.ExpiresAt
+	}
+
+	return fence, nil
+}
+
+// resolveHysteresis overrides the defaults with any per-fence settings
+func resolveHysteresis(defaults Hysteresis, exitBuffer *float64, minPings *int, minSeconds *int64) Hysteresis {
+	h := defaults
+	if exitBuffer != nil {
+		h.ExitBuffer = *exitBuffer
+	}
+	if minPings != nil {
+		h.MinPings = *minPings
+	}
+	if minSeconds != nil {
+		h.MinSeconds = *minSeconds
+	}
+	return h
+}
+
+// ParseGeometry converts a GeoJSON geometry into a fence shape. Point
+// geometries become circles with the given radius in meters, LineString
+// and MultiLineString geometries become corridors with the given buffer
+// width in meters.
+func ParseGeometry(g Geometry, radius, buffer float64) (Shape, error) {
+	switch g.Type {
+	case "Point":
+		var coords []float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid Point coordinates: %w", err)
+		}
+		center, err := toLocation(coords)
+		if err != nil {
+			return nil, err
+		}
+		if radius <= 0 {
+			return nil, fmt.Errorf("radius must be positive for Point geometry")
+		}
+		return &Circle{Center: center, Radius: radius}, nil
+
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid Polygon coordinates: %w", err)
+		}
+		return toPolygon(coords)
+
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid MultiPolygon coordinates: %w", err)
+		}
+		multi := &MultiPolygon{Polygons: make([]Polygon, 0, len(coords))}
+		for _, polyCoords := range coords {
+			polygon, err := toPolygon(polyCoords)
+			if err != nil {
+				return nil, err
+			}
+			multi.Polygons = append(multi.Polygons, *polygon)
+		}
+		if len(multi.Polygons) == 0 {
+			return nil, fmt.Errorf("MultiPolygon must contain at least one polygon")
+		}
+		return multi, nil
+
+	case "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid LineString coordinates: %w", err)
+		}
+		return toCorridor([][][]float64{coords}, buffer)
+
+	case "MultiLineString":
+		var coords [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("invalid MultiLineString coordinates: %w", err)
+		}
+		return toCorridor(coords, buffer)
+
+	default:
+		return nil, fmt.Errorf("unsupported geometry type: %q", g.Type)
+	}
+}
+
+// toPolygon converts GeoJSON polygon rings into a Polygon
+func toPolygon(coords [][][]float64) (*Polygon, error) {
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("polygon must have an outer ring")
+	}
+
+	polygon := &Polygon{Rings: make([][]models.Location, 0, len(coords))}
+	for _, ringCoords := range coords {
+		if len(ringCoords) < 4 {
+			return nil, fmt.Errorf("polygon ring must have at least 4 positions")
+		}
+
+		ring := make([]models.Location, 0, len(ringCoords))
+		for _, pos := range ringCoords {
+			loc, err := toLocation(pos)
+			if err != nil {
+				return nil, err
+			}
+			ring = append(ring, loc)
+		}
+		polygon.Rings = append(polygon.Rings, ring)
+	}
+
+	return polygon, nil
+}
+
+// toCorridor converts GeoJSON line strings into a Corridor
+func toCorridor(coords [][][]float64, buffer float64) (*Corridor, error) {
+	if buffer <= 0 {
+		return nil, fmt.Errorf("buffer must be positive for LineString geometry")
+	}
+
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("corridor must contain at least one line")
+	}
+
+	corridor := &Corridor{Paths: make([][]models.Location, 0, len(coords)), Buffer: buffer}
+	for _, lineCoords := range coords {
+		if len(lineCoords) < 2 {
+			return nil, fmt.Errorf("line must have at least 2 positions")
+		}
+
+		path := make([]models.Location, 0, len(lineCoords))
+		for _, pos := range lineCoords {
+			loc, err := toLocation(pos)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, loc)
+		}
+		corridor.Paths = append(corridor.Paths, path)
+	}
+
+	return corridor, nil
+}
+
+// toLocation converts a GeoJSON [lon, lat] position into a Location
+func toLocation(pos []float64) (models.Location, error) {
+	if len(pos) < 2 {
+		return models.Location{}, fmt.Errorf("position must have longitude and latitude")
+	}
+
+	lon, lat := pos[0], pos[1]
+	if lat < -90 || lat > 90 {
+		return models.Location{}, fmt.Errorf("invalid latitude: %f", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return models.Location{}, fmt.Errorf("invalid longitude: %f", lon)
+	}
+
+	return models.Location{Latitude: lat, Longitude: lon}, nil
+}
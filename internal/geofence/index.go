@@ -0,0 +1,109 @@
+package geofence
+
+import (
+	"math"
+)
+
+const (
+	// cellSize is the edge length of one grid cell in degrees (~1.1 km)
+	cellSize = 0.01
+
+	// maxCellsPerFence caps how many cells a single fence may occupy.
+	// Fences larger than this are kept in a separate list and tested on
+	// every lookup instead of bloating the grid.
+	maxCellsPerFence = 4096
+)
+
+// cellKey identifies one cell of the grid
+type cellKey struct {
+	x int32
+	y int32
+}
+
+// gridIndex is a fixed-size lat/lon grid that maps each cell to the fences
+// whose bounding box overlaps it, so a lookup only tests the handful of
+// fences near the point instead of every registered fence
+type gridIndex struct {
+	cells map[cellKey][]*Fence
+	large []*Fence
+}
+
+// newGridIndex creates an empty grid index
+func newGridIndex() *gridIndex {
+	return &gridIndex{cells: make(map[cellKey][]*Fence)}
+}
+
+// insert adds a fence to every cell its bounding box overlaps
+func (g *gridIndex) insert(fence *Fence) {
+	minX, minY, maxX, maxY := cellRange(fence.Shape.Bounds())
+	if cellCount(minX, minY, maxX, maxY) > maxCellsPerFence {
+		g.large = append(g.large, fence)
+		return
+	}
+
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			key := cellKey{x: x, y: y}
+			g.cells[key] = append(g.cells[key], fence)
+		}
+	}
+}
+
+// remove deletes a fence from every cell it was inserted into
+func (g *gridIndex) remove(fence *Fence) {
+	minX, minY, maxX, maxY := cellRange(fence.Shape.Bounds())
+	if cellCount(minX, minY, maxX, maxY) > maxCellsPerFence {
+		g.large = without(g.large, fence)
+		return
+	}
+
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			key := cellKey{x: x, y: y}
+			remaining := without(g.cells[key], fence)
+			if len(remaining) == 0 {
+				delete(g.cells, key)
+				continue
+			}
+			g.cells[key] = remaining
+		}
+	}
+}
+
+// candidates returns the fences that may contain the point
+func (g *gridIndex) candidates(lat, lon float64) []*Fence {
+	cell := g.cells[cellKey{x: cellCoord(lon), y: cellCoord(lat)}]
+	if len(g.large) == 0 {
+		return cell
+	}
+
+	result := make([]*Fence, 0, len(cell)+len(g.large))
+	result = append(result, cell...)
+	return append(result, g.large...)
+}
+
+// cellCoord converts a coordinate in degrees to a grid cell coordinate
+func cellCoord(deg float64) int32 {
+	return int32(math.Floor(deg / cellSize))
+}
+
+// cellRange returns the inclusive cell range covered by a bounding box
+func cellRange(b Bounds) (minX, minY, maxX, maxY int32) {
+	return cellCoord(b.MinLon), cellCoord(b.MinLat), cellCoord(b.MaxLon), cellCoord(b.MaxLat)
+}
+
+// cellCount returns the number of cells in an inclusive cell range
+func cellCount(minX, minY, maxX, maxY int32) int64 {
+	return int64(maxX-minX+1) * int64(maxY-minY+1)
+}
+
+// without returns the fences in list other than the given fence
+func without(list []*Fence, fence *Fence) []*Fence {
+	result := list[:0:0]
+	for _, f := range list {
+		if f != fence {
+			result = append(result, f)
+		}
+	}
+	return result
+}
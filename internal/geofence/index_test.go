@@ -0,0 +1,102 @@
+package geofence
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+func circleFence(id string, lat, lon, radius float64) *Fence {
+	return &Fence{ID: id, Name: id, Shape: &Circle{Center: models.Location{Latitude: lat, Longitude: lon}, Radius: radius}}
+}
+
+func fenceIDs(fences []*Fence) map[string]bool {
+	ids := make(map[string]bool, len(fences))
+	for _, f := range fences {
+		ids[f.ID] = true
+	}
+	return ids
+}
+
+func TestGridIndexCandidates(t *testing.T) {
+	g := newGridIndex()
+	// blok-m straddles a cell boundary, kota sits in a single cell far away
+	blokM := circleFence("blok-m", -6.2, 106.8, 300)
+	kota := circleFence("kota", -6.135, 106.815, 100)
+	g.insert(blokM)
+	g.insert(kota)
+
+	if ids := fenceIDs(g.candidates(-6.2, 106.8)); !ids["blok-m"] || ids["kota"] {
+		t.Errorf("candidates at blok-m = %v", ids)
+	}
+	// 200m west of the center is in the neighbouring cell, still within
+	// the bounding box
+	if ids := fenceIDs(g.candidates(-6.2, 106.8-200/metersPerDegree)); !ids["blok-m"] {
+		t.Errorf("candidates west of blok-m = %v, want blok-m", ids)
+	}
+	if got := g.candidates(-6.3, 106.9); len(got) != 0 {
+		t.Errorf("candidates in an empty cell = %v, want none", fenceIDs(got))
+	}
+
+	g.remove(blokM)
+	if got := g.candidates(-6.2, 106.8); len(got) != 0 {
+		t.Errorf("candidates after remove = %v, want none", fenceIDs(got))
+	}
+	if len(g.cells) == 0 {
+		t.Fatal("removing one fence emptied the grid")
+	}
+	for key, fences := range g.cells {
+		if fenceIDs(fences)["blok-m"] {
+			t.Errorf("cell %v still holds the removed fence", key)
+		}
+	}
+}
+
+func TestGridIndexLargeFence(t *testing.T) {
+	g := newGridIndex()
+	jabodetabek := circleFence("jabodetabek", -6.2, 106.8, 50000)
+	g.insert(jabodetabek)
+
+	if len(g.large) != 1 || len(g.cells) != 0 {
+		t.Fatalf("large fence went into %d cells and %d large entries", len(g.cells), len(g.large))
+	}
+	// Large fences are returned everywhere and left to the exact test
+	if ids := fenceIDs(g.candidates(10, 10)); !ids["jabodetabek"] {
+		t.Errorf("large fence missing from candidates")
+	}
+
+	g.remove(jabodetabek)
+	if len(g.large) != 0 {
+		t.Errorf("large fence left after remove")
+	}
+}
+
+func TestCircleBoundsNearPole(t *testing.T) {
+	tests := []struct {
+		name   string
+		circle Circle
+	}{
+		{"touching the north pole", Circle{Center: models.Location{Latitude: 89.999, Longitude: 20}, Radius: 1000}},
+		{"at the south pole", Circle{Center: models.Location{Latitude: -90, Longitude: 0}, Radius: 10}},
+		{"wider than the meridians", Circle{Center: models.Location{Latitude: 89.9, Longitude: 0}, Radius: 10000}},
+	}
+
+	for _, tt := range tests {
+		b := tt.circle.Bounds()
+		for _, v := range []float64{b.MinLat, b.MinLon, b.MaxLat, b.MaxLon} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Errorf("%s: bounds %+v aren't finite", tt.name, b)
+			}
+		}
+		if b.MinLon != -180 || b.MaxLon != 180 || b.MinLat < -90 || b.MaxLat > 90 {
+			t.Errorf("%s: bounds %+v, want every longitude within valid latitudes", tt.name, b)
+		}
+	}
+
+	b := (&Circle{Center: models.Location{Latitude: 60, Longitude: 10}, Radius: 1000}).Bounds()
+	if dLon := b.MaxLon - 10; math.Abs(dLon-2*(b.MaxLat-60)) > 1e-3 {
+		// At 60° a degree of longitude is half as long as one of latitude
+		t.Errorf("longitude span at 60° = %.5f°, want about twice the latitude span", dLon)
+	}
+}
@@ -0,0 +1,160 @@
+package geofence
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// PointIndex keeps the current position of every vehicle in a grid so
+// viewport and radius queries only visit the cells they cover
+type PointIndex struct {
+	mu     sync.RWMutex
+	points map[string]*models.VehicleLocation
+	cells  map[cellKey]map[string]struct{}
+}
+
+// NewPointIndex creates an empty point index
+func NewPointIndex() *PointIndex {
+	return &PointIndex{
+		points: make(map[string]*models.VehicleLocation),
+		cells:  make(map[cellKey]map[string]struct{}),
+	}
+}
+
+// Load adds many locations to the index, typically the latest stored
+// location of every vehicle at startup
+func (p *PointIndex) Load(locs []models.VehicleLocation) {
+	for i := range locs {
+		p.Update(&locs[i])
+	}
+}
+
+// Update moves a vehicle to the given location. Locations older than the
+// indexed one are ignored so out-of-order messages can't move a vehicle
+// back in time. It reports whether the index changed.
+func (p *PointIndex) Update(loc *models.VehicleLocation) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev, ok := p.points[loc.VehicleID]
+	if ok && loc.Timestamp < prev.Timestamp {
+		return false
+	}
+
+	key := cellKey{x: cellCoord(loc.Longitude), y: cellCoord(loc.Latitude)}
+	if ok {
+		prevKey := cellKey{x: cellCoord(prev.Longitude), y: cellCoord(prev.Latitude)}
+		if prevKey != key {
+			p.removeFromCell(prevKey, loc.VehicleID)
+		}
+	}
+
+	cell, exists := p.cells[key]
+	if !exists {
+		cell = make(map[string]struct{})
+		p.cells[key] = cell
+	}
+	cell[loc.VehicleID] = struct{}{}
+
+	stored := *loc
+	p.points[loc.VehicleID] = &stored
+	return true
+}
+
+// Len returns the number of indexed vehicles
+func (p *PointIndex) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.points)
+}
+
+// Within returns the vehicles inside the bounding box, sorted by distance
+// from its center
+func (p *PointIndex) Within(b Bounds) []models.NearbyVehicle {
+	centerLat := (b.MinLat + b.MaxLat) / 2
+	centerLon := (b.MinLon + b.MaxLon) / 2
+
+	p.mu.RLock()
+	var result []models.NearbyVehicle
+	p.visit(b, func(loc *models.VehicleLocation) {
+		if b.Contains(loc.Latitude, loc.Longitude) {
+			result = append(result, nearby(loc, centerLat, centerLon))
+		}
+	})
+	p.mu.RUnlock()
+
+	sortByDistance(result)
+	return result
+}
+
+// Nearby returns at most limit vehicles within radius meters of the point,
+// nearest first. A limit of 0 returns every match.
+func (p *PointIndex) Nearby(lat, lon, radius float64, limit int) []models.NearbyVehicle {
+	circle := Circle{Center: models.Location{Latitude: lat, Longitude: lon}, Radius: radius}
+
+	p.mu.RLock()
+	var result []models.NearbyVehicle
+	p.visit(circle.Bounds(), func(loc *models.VehicleLocation) {
+		if v := nearby(loc, lat, lon); v.DistanceMeters <= radius {
+			result = append(result, v)
+		}
+	})
+	p.mu.RUnlock()
+
+	sortByDistance(result)
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// visit calls fn for every vehicle in the cells overlapping the bounding
+// box. Very large boxes fall back to visiting every vehicle, which is
+// cheaper than walking mostly empty cells. The caller must hold the lock.
+func (p *PointIndex) visit(b Bounds, fn func(loc *models.VehicleLocation)) {
+	minX, minY, maxX, maxY := cellRange(b)
+	if cellCount(minX, minY, maxX, maxY) > int64(len(p.cells)) {
+		for _, loc := range p.points {
+			fn(loc)
+		}
+		return
+	}
+
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for id := range p.cells[cellKey{x: x, y: y}] {
+				fn(p.points[id])
+			}
+		}
+	}
+}
+
+// removeFromCell removes a vehicle from a cell, dropping the cell when it
+// becomes empty. The caller must hold the lock.
+func (p *PointIndex) removeFromCell(key cellKey, vehicleID string) {
+	cell := p.cells[key]
+	delete(cell, vehicleID)
+	if len(cell) == 0 {
+		delete(p.cells, key)
+	}
+}
+
+// nearby pairs a copy of a location with its distance to a point
+func nearby(loc *models.VehicleLocation, lat, lon float64) models.NearbyVehicle {
+	return models.NearbyVehicle{
+		VehicleLocation: *loc,
+		DistanceMeters:  haversineDistance(lat, lon, loc.Latitude, loc.Longitude),
+	}
+}
+
+// sortByDistance orders vehicles nearest first, breaking ties by ID
+func sortByDistance(vehicles []models.NearbyVehicle) {
+	sort.Slice(vehicles, func(i, j int) bool {
+		if vehicles[i].DistanceMeters != vehicles[j].DistanceMeters {
+			return vehicles[i].DistanceMeters < vehicles[j].DistanceMeters
+		}
+		return vehicles[i].VehicleID < vehicles[j].VehicleID
+	})
+}
@@ -0,0 +1,201 @@
+package geofence
+
+import (
+	"math"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// metersPerDegree is the approximate length of one degree of latitude
+const metersPerDegree = 111320.0
+
+// Shape is a geographic area that can be tested for containment
+type Shape interface {
+	// Contains reports whether the given point lies inside the shape
+	Contains(lat, lon float64) bool
+	// Bounds returns the bounding box enclosing the shape
+	Bounds() Bounds
+	// Distance returns the distance in meters from the point to the
+	// shape, or 0 when the point is inside
+	Distance(lat, lon float64) float64
+}
+
+// Bounds is an axis-aligned bounding box in degrees
+type Bounds struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains reports whether the point lies inside the bounding box
+func (b Bounds) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// extend grows the bounding box so that it includes the point
+func (b *Bounds) extend(lat, lon float64) {
+	b.MinLat = math.Min(b.MinLat, lat)
+	b.MinLon = math.Min(b.MinLon, lon)
+	b.MaxLat = math.Max(b.MaxLat, lat)
+	b.MaxLon = math.Max(b.MaxLon, lon)
+}
+
+// emptyBounds returns a bounding box that any extend call will replace
+func emptyBounds() Bounds {
+	return Bounds{
+		MinLat: math.Inf(1),
+		MinLon: math.Inf(1),
+		MaxLat: math.Inf(-1),
+		MaxLon: math.Inf(-1),
+	}
+}
+
+// Circle is a fence defined by a center point and a radius in meters
+type Circle struct {
+	Center models.Location
+	Radius float64 // in meters
+}
+
+// Contains reports whether the point is within the circle radius
+func (c *Circle) Contains(lat, lon float64) bool {
+	return haversineDistance(c.Center.Latitude, c.Center.Longitude, lat, lon) <= c.Radius
+}
+
+// Distance returns how far the point lies outside the circle in meters
+func (c *Circle) Distance(lat, lon float64) float64 {
+	d := haversineDistance(c.Center.Latitude, c.Center.Longitude, lat, lon)
+	return math.Max(0, d-c.Radius)
+}
+
+// Bounds returns the bounding box of the circle. The longitude span is
+// taken at the latitude furthest from the equator, where the circle is
+// widest in degrees, and a circle reaching a pole spans every longitude.
+func (c *Circle) Bounds() Bounds {
+	dLat := c.Radius / metersPerDegree
+	minLat := c.Center.Latitude - dLat
+	maxLat := c.Center.Latitude + dLat
+
+	if minLat <= -90 || maxLat >= 90 {
+		return Bounds{MinLat: math.Max(minLat, -90), MinLon: -180, MaxLat: math.Min(maxLat, 90), MaxLon: 180}
+	}
+
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat))
+	dLon := c.Radius / (metersPerDegree * math.Cos(widest*math.Pi/180))
+	if dLon >= 180 {
+		return Bounds{MinLat: minLat, MinLon: -180, MaxLat: maxLat, MaxLon: 180}
+	}
+
+	return Bounds{
+		MinLat: minLat,
+		MinLon: c.Center.Longitude - dLon,
+		MaxLat: maxLat,
+		MaxLon: c.Center.Longitude + dLon,
+	}
+}
+
+// Polygon is a fence defined by one outer ring and optional holes.
+// The first ring is the outer boundary, every following ring is a hole.
+// Rings may be open or closed (first point repeated at the end).
+type Polygon struct {
+	Rings [][]models.Location
+}
+
+// Contains reports whether the point is inside the outer ring and
+// outside every hole
+func (p *Polygon) Contains(lat, lon float64) bool {
+	if len(p.Rings) == 0 || !ringContains(p.Rings[0], lat, lon) {
+		return false
+	}
+
+	for _, hole := range p.Rings[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Distance returns the distance in meters from the point to the nearest
+// edge of the polygon, or 0 when the point is inside
+func (p *Polygon) Distance(lat, lon float64) float64 {
+	if len(p.Rings) == 0 {
+		return math.Inf(1)
+	}
+	if p.Contains(lat, lon) {
+		return 0
+	}
+
+	best := math.Inf(1)
+	for _, ring := range p.Rings {
+		best = math.Min(best, distanceToRing(ring, lat, lon))
+	}
+	return best
+}
+
+// Bounds returns the bounding box of the outer ring
+func (p *Polygon) Bounds() Bounds {
+	b := emptyBounds()
+	if len(p.Rings) > 0 {
+		for _, pt := range p.Rings[0] {
+			b.extend(pt.Latitude, pt.Longitude)
+		}
+	}
+	return b
+}
+
+// MultiPolygon is a fence made of several disjoint polygons
+type MultiPolygon struct {
+	Polygons []Polygon
+}
+
+// Contains reports whether the point is inside any of the polygons
+func (m *MultiPolygon) Contains(lat, lon float64) bool {
+	for i := range m.Polygons {
+		if m.Polygons[i].Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Distance returns the distance in meters to the nearest polygon, or 0
+// when the point is inside any of them
+func (m *MultiPolygon) Distance(lat, lon float64) float64 {
+	best := math.Inf(1)
+	for i := range m.Polygons {
+		best = math.Min(best, m.Polygons[i].Distance(lat, lon))
+	}
+	return best
+}
+
+// Bounds returns the bounding box enclosing every polygon
+func (m *MultiPolygon) Bounds() Bounds {
+	b := emptyBounds()
+	for i := range m.Polygons {
+		pb := m.Polygons[i].Bounds()
+		b.extend(pb.MinLat, pb.MinLon)
+		b.extend(pb.MaxLat, pb.MaxLon)
+	}
+	return b
+}
+
+// ringContains tests a point against a single ring using the ray casting
+// (even-odd) rule. Coordinates are treated as planar, which is accurate
+// enough for fences the size of a depot or terminal.
+func ringContains(ring []models.Location, lat, lon float64) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		yi, xi := ring[i].Latitude, ring[i].Longitude
+		yj, xj := ring[j].Latitude, ring[j].Longitude
+
+		if (yi > lat) != (yj > lat) &&
+			lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
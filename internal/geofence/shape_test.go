@@ -0,0 +1,87 @@
+package geofence
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// ring builds a closed ring from lat, lon pairs
+func ring(coords ...float64) []models.Location {
+	var r []models.Location
+	for i := 0; i+1 < len(coords); i += 2 {
+		r = append(r, models.Location{Latitude: coords[i], Longitude: coords[i+1]})
+	}
+	return append(r, r[0])
+}
+
+// terminal is a 0.02° square with a 0.004° hole in the middle, like a
+// terminal fence with a building cut out of it
+var terminal = &Polygon{Rings: [][]models.Location{
+	ring(-6.21, 106.79, -6.21, 106.81, -6.19, 106.81, -6.19, 106.79),
+	ring(-6.202, 106.798, -6.202, 106.802, -6.198, 106.802, -6.198, 106.798),
+}}
+
+func TestPolygonContains(t *testing.T) {
+	tests := map[string]struct {
+		lat, lon float64
+		want     bool
+	}{
+		"between outer ring and hole": {-6.205, 106.795, true},
+		"inside the hole":             {-6.2, 106.8, false},
+		"outside the outer ring":      {-6.22, 106.8, false},
+		"east of the outer ring":      {-6.2, 106.82, false},
+		"north of the hole":           {-6.195, 106.8, true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := terminal.Contains(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("Contains(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonContainsOpenRing(t *testing.T) {
+	closed := terminal.Rings[0]
+	open := &Polygon{Rings: [][]models.Location{closed[:len(closed)-1]}}
+
+	if !open.Contains(-6.2, 106.8) {
+		t.Error("open ring doesn't contain its center")
+	}
+	if (&Polygon{}).Contains(-6.2, 106.8) {
+		t.Error("polygon without rings contains a point")
+	}
+}
+
+func TestPolygonDistance(t *testing.T) {
+	// The hole counts as outside, so a point in its middle is as far from
+	// the polygon as from the hole edge
+	got := terminal.Distance(-6.2, 106.8)
+	want := 0.002 * metersPerDegree
+	if math.Abs(got-want) > 5 {
+		t.Errorf("distance from the hole center = %.1fm, want about %.1fm", got, want)
+	}
+
+	if d := terminal.Distance(-6.205, 106.795); d != 0 {
+		t.Errorf("distance from inside = %.1fm, want 0", d)
+	}
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	m := &MultiPolygon{Polygons: []Polygon{
+		*terminal,
+		{Rings: [][]models.Location{ring(-6.1, 106.7, -6.1, 106.71, -6.09, 106.71, -6.09, 106.7)}},
+	}}
+
+	for _, p := range []models.Location{{Latitude: -6.205, Longitude: 106.795}, {Latitude: -6.095, Longitude: 106.705}} {
+		if !m.Contains(p.Latitude, p.Longitude) {
+			t.Errorf("multipolygon doesn't contain %v", p)
+		}
+	}
+	if m.Contains(-6.2, 106.8) {
+		t.Error("multipolygon contains a point in a hole")
+	}
+}
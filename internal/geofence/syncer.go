@@ -0,0 +1,85 @@
+package geofence
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// syncAttempts is how many times a sync is retried when fences change while
+// it loads them
+const syncAttempts = 3
+
+// Source provides the geofences stored outside of the checker
+type Source interface {
+	ListActiveGeofences() ([]models.Geofence, error)
+}
+
+// Syncer periodically reloads stored geofences into a checker so fences
+// created, changed or removed through the API take effect without a
+// restart, including on other server instances
+type Syncer struct {
+	checker  *Checker
+	source   Source
+	interval time.Duration
+}
+
+// NewSyncer creates a new geofence syncer
+func NewSyncer(checker *Checker, source Source, interval time.Duration) *Syncer {
+	return &Syncer{
+		checker:  checker,
+		source:   source,
+		interval: interval,
+	}
+}
+
+// Sync loads every active geofence from the source into the checker.
+// Geofences with invalid geometry are skipped and logged. When a fence is
+// added or removed through the API while loading, the load is repeated so
+// the change isn't lost.
+func (s *Syncer) Sync() error {
+	for attempt := 0; attempt < syncAttempts; attempt++ {
+		generation := s.checker.Generation()
+
+		geofences, err := s.source.ListActiveGeofences()
+		if err != nil {
+			return err
+		}
+
+		fences := make([]*Fence, 0, len(geofences))
+		for i := range geofences {
+			fence, err := s.checker.FenceFromModel(&geofences[i])
+			if err != nil {
+				log.Printf("Skipping geofence %s: %v", geofences[i].ID, err)
+				continue
+			}
+			fences = append(fences, fence)
+		}
+
+		if s.checker.Sync(fences, generation) {
+			return nil
+		}
+	}
+
+	return errors.New("geofences kept changing while syncing")
+}
+
+// Run syncs the checker on every interval until the context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(); err != nil {
+				log.Printf("Failed to sync geofences: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package geofence
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// fenceState tracks one vehicle's presence inside one fence. A state
+// exists while the vehicle is confirmed inside or while an entry is still
+// being debounced.
+type fenceState struct {
+	fence     *Fence
+	inside    bool // confirmed inside, an entry event has been sent
+	enteredAt int64
+	dwellSent bool
+
+	// Consecutive pings contradicting the confirmed state
+	pendingPings int
+	pendingSince int64
+}
+
+// vehicleState tracks every fence a vehicle is currently inside
+type vehicleState struct {
+	lastTimestamp int64
+	fences        map[string]*fenceState
+}
+
+// Tracker turns per-ping fence matches into entry, exit and dwell
+// transitions so a vehicle parked inside a fence produces one entry event
+// instead of one event per location update. Transitions are debounced
+// using each fence's Hysteresis to suppress GPS jitter at the boundary.
+type Tracker struct {
+	checker   *Checker
+	dwellTime int64 // in seconds, 0 disables dwell events
+
+	mu       sync.Mutex
+	vehicles map[string]*vehicleState
+}
+
+// NewTracker creates a new geofence state tracker
+func NewTracker(checker *Checker, dwellTime time.Duration) *Tracker {
+	return &Tracker{
+		checker:   checker,
+		dwellTime: int64(dwellTime / time.Second),
+		vehicles:  make(map[string]*vehicleState),
+	}
+}
+
+// Process checks a location against the registered fences and returns the
+// events caused by it. Locations older than the last processed location
+// for the same vehicle are ignored.
+func (t *Tracker) Process(loc *models.VehicleLocation) []*models.GeofenceEvent {
+	matches := t.checker.IsInsideGeofence(loc)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.vehicles[loc.VehicleID]
+	if !ok {
+		state = &vehicleState{fences: make(map[string]*fenceState)}
+		t.vehicles[loc.VehicleID] = state
+	}
+
+	if loc.Timestamp < state.lastTimestamp {
+		return nil
+	}
+	state.lastTimestamp = loc.Timestamp
+
+	matched := make(map[string]*Fence, len(matches))
+	for _, fence := range matches {
+		matched[fence.ID] = fence
+		if _, ok := state.fences[fence.ID]; !ok {
+			state.fences[fence.ID] = &fenceState{fence: fence}
+		}
+	}
+
+	var events []*models.GeofenceEvent
+	for id, fs := range state.fences {
+		// Pick up geometry or hysteresis changes made since the last ping
+		current := t.checker.Fence(id)
+		if current != nil {
+			fs.fence = current
+		}
+
+		if !fs.inside {
+			events = t.processOutside(state, fs, matched[id] != nil, loc, events)
+			continue
+		}
+
+		observed := current != nil && current.activeAt(loc.Timestamp) &&
+			current.holds(loc.Latitude, loc.Longitude)
+		events = t.processInside(state, fs, observed, loc, events)
+	}
+
+	return events
+}
+
+// processOutside debounces a pending entry into a fence
+func (t *Tracker) processOutside(state *vehicleState, fs *fenceState, observed bool, loc *models.VehicleLocation, events []*models.GeofenceEvent) []*models.GeofenceEvent {
+	if !observed {
+		delete(state.fences, fs.fence.ID)
+		return events
+	}
+
+	if !t.confirm(fs, loc.Timestamp) {
+		return events
+	}
+
+	fs.inside = true
+	fs.enteredAt = fs.pendingSince
+	fs.pendingPings = 0
+
+	events = append(events, newEvent(models.EventGeofenceEntry, fs.fence, loc, 0))
+	return t.checkDwell(fs, loc, events)
+}
+
+// processInside debounces an exit from a fence and emits dwell events
+func (t *Tracker) processInside(state *vehicleState, fs *fenceState, observed bool, loc *models.VehicleLocation, events []*models.GeofenceEvent) []*models.GeofenceEvent {
+	if observed {
+		fs.pendingPings = 0
+		return t.checkDwell(fs, loc, events)
+	}
+
+	if !t.confirm(fs, loc.Timestamp) {
+		return events
+	}
+
+	delete(state.fences, fs.fence.ID)
+	return append(events, newEvent(models.EventGeofenceExit, fs.fence, loc, fs.pendingSince-fs.enteredAt))
+}
+
+// confirm records one ping contradicting the confirmed state and reports
+// whether enough pings and time have passed for the transition to count
+func (t *Tracker) confirm(fs *fenceState, timestamp int64) bool {
+	if fs.pendingPings == 0 {
+		fs.pendingSince = timestamp
+	}
+	fs.pendingPings++
+
+	h := fs.fence.Hysteresis
+	return fs.pendingPings >= h.MinPings && timestamp-fs.pendingSince >= h.MinSeconds
+}
+
+// checkDwell emits a dwell event once the vehicle has been inside the
+// fence for the configured dwell time
+func (t *Tracker) checkDwell(fs *fenceState, loc *models.VehicleLocation, events []*models.GeofenceEvent) []*models.GeofenceEvent {
+	dwell := loc.Timestamp - fs.enteredAt
+	if t.dwellTime > 0 && !fs.dwellSent && dwell >= t.dwellTime {
+		fs.dwellSent = true
+		events = append(events, newEvent(models.EventGeofenceDwell, fs.fence, loc, dwell))
+	}
+	return events
+}
+
+// newEvent builds a geofence event for a vehicle location
+func newEvent(event string, fence *Fence, loc *models.VehicleLocation, dwell int64) *models.GeofenceEvent {
+	return &models.GeofenceEvent{
+		VehicleID:    loc.VehicleID,
+		Event:        event,
+		GeofenceID:   fence.ID,
+		GeofenceName: fence.Name,
+		DwellSeconds: dwell,
+		Location: models.Location{
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+		},
+		Timestamp: loc.Timestamp,
+	}
+}
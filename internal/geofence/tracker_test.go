@@ -0,0 +1,164 @@
+package geofence
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+var fenceCenter = models.Location{Latitude: -6.2, Longitude: 106.8}
+
+// north returns the point the given meters north of the test fence center
+func north(meters float64) models.Location {
+	return models.Location{Latitude: fenceCenter.Latitude + meters/metersPerDegree, Longitude: fenceCenter.Longitude}
+}
+
+var (
+	inside  = north(0)
+	buffer  = north(105) // outside the 100m fence, within a 10m exit buffer
+	outside = north(300)
+)
+
+type ping struct {
+	ts   int64
+	at   models.Location
+	want []string // event:dwell_seconds
+}
+
+func TestTrackerHysteresis(t *testing.T) {
+	tests := []struct {
+		name       string
+		hysteresis Hysteresis
+		dwellTime  time.Duration
+		pings      []ping
+	}{
+		{
+			name:       "single ping enters and exits",
+			hysteresis: Hysteresis{MinPings: 1},
+			pings: []ping{
+				{ts: 0, at: outside},
+				{ts: 10, at: inside, want: []string{"geofence_entry:0"}},
+				{ts: 20, at: inside},
+				{ts: 40, at: outside, want: []string{"geofence_exit:30"}},
+				{ts: 50, at: outside},
+			},
+		},
+		{
+			name:       "entry needs consecutive pings",
+			hysteresis: Hysteresis{MinPings: 2},
+			pings: []ping{
+				{ts: 0, at: inside},
+				{ts: 5, at: outside},
+				{ts: 10, at: inside},
+				{ts: 15, at: inside, want: []string{"geofence_entry:0"}},
+			},
+		},
+		{
+			name:       "exit needs consecutive pings and dwell runs from the first",
+			hysteresis: Hysteresis{MinPings: 2},
+			pings: []ping{
+				{ts: 0, at: inside},
+				{ts: 5, at: inside, want: []string{"geofence_entry:0"}},
+				{ts: 10, at: outside},
+				{ts: 15, at: inside},
+				{ts: 20, at: outside},
+				{ts: 25, at: outside, want: []string{"geofence_exit:20"}},
+			},
+		},
+		{
+			name:       "entry needs minimum time",
+			hysteresis: Hysteresis{MinPings: 1, MinSeconds: 30},
+			pings: []ping{
+				{ts: 0, at: inside},
+				{ts: 29, at: inside},
+				{ts: 30, at: inside, want: []string{"geofence_entry:0"}},
+			},
+		},
+		{
+			name:       "minimum time restarts after a contradicting ping",
+			hysteresis: Hysteresis{MinPings: 1, MinSeconds: 30},
+			pings: []ping{
+				{ts: 0, at: inside},
+				{ts: 20, at: outside},
+				{ts: 40, at: inside},
+				{ts: 60, at: inside},
+				{ts: 70, at: inside, want: []string{"geofence_entry:0"}},
+			},
+		},
+		{
+			name:       "exit buffer holds a vehicle just outside",
+			hysteresis: Hysteresis{MinPings: 1, ExitBuffer: 10},
+			pings: []ping{
+				{ts: 0, at: inside, want: []string{"geofence_entry:0"}},
+				{ts: 10, at: buffer},
+				{ts: 20, at: outside, want: []string{"geofence_exit:20"}},
+			},
+		},
+		{
+			name:       "buffer zone doesn't count as an entry",
+			hysteresis: Hysteresis{MinPings: 1, ExitBuffer: 10},
+			pings: []ping{
+				{ts: 0, at: buffer},
+				{ts: 10, at: buffer},
+			},
+		},
+		{
+			name:       "dwell is sent once",
+			hysteresis: Hysteresis{MinPings: 1},
+			dwellTime:  60 * time.Second,
+			pings: []ping{
+				{ts: 0, at: inside, want: []string{"geofence_entry:0"}},
+				{ts: 59, at: inside},
+				{ts: 60, at: inside, want: []string{"geofence_dwell:60"}},
+				{ts: 120, at: inside},
+				{ts: 130, at: outside, want: []string{"geofence_exit:130"}},
+			},
+		},
+		{
+			name:       "late pings are ignored",
+			hysteresis: Hysteresis{MinPings: 1},
+			pings: []ping{
+				{ts: 100, at: inside, want: []string{"geofence_entry:0"}},
+				{ts: 50, at: outside},
+				{ts: 110, at: inside},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewChecker(&config.Config{GeofenceID: "default", GeofenceRadius: 50})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checker.AddFence(&Fence{
+				ID:         "fence",
+				Name:       "Fence",
+				Shape:      &Circle{Center: fenceCenter, Radius: 100},
+				Hysteresis: tt.hysteresis,
+			})
+			tracker := NewTracker(checker, tt.dwellTime)
+
+			for _, p := range tt.pings {
+				loc := &models.VehicleLocation{
+					VehicleID: "B1234XYZ",
+					Latitude:  p.at.Latitude,
+					Longitude: p.at.Longitude,
+					Timestamp: p.ts,
+				}
+
+				var got []string
+				for _, event := range tracker.Process(loc) {
+					got = append(got, fmt.Sprintf("%s:%d", event.Event, event.DwellSeconds))
+				}
+				if !reflect.DeepEqual(got, p.want) {
+					t.Errorf("ping at %d: got events %v, want %v", p.ts, got, p.want)
+				}
+			}
+		})
+	}
+}
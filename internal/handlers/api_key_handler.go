@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/auth"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+// APIKeyHandler handles HTTP requests for API key management
+type APIKeyHandler struct {
+	repo          *repository.APIKeyRepository
+	authenticator *auth.Authenticator
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler
+func NewAPIKeyHandler(repo *repository.APIKeyRepository, authenticator *auth.Authenticator) *APIKeyHandler {
+	return &APIKeyHandler{repo: repo, authenticator: authenticator}
+}
+
+// apiKeyRequest is the request body for creating an API key
+type apiKeyRequest struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	ExpiresAt *int64 `json:"expires_at"`
+}
+
+// CreateAPIKey handles POST /api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req apiKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "invalid request body",
+		})
+	}
+
+	if req.ExpiresAt != nil && *req.ExpiresAt <= time.Now().Unix() {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "expires_at must be in the future",
+		})
+	}
+
+	key, hash, err := auth.NewAPIKey(req.Name, req.Role, req.ExpiresAt)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if err := h.repo.CreateAPIKey(&key.APIKey, hash); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to create api key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+// ListAPIKeys handles GET /api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.repo.ListAPIKeys()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to list api keys",
+		})
+	}
+
+	return c.JSON(keys)
+}
+
+// RevokeAPIKey handles DELETE /api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	found, err := h.repo.RevokeAPIKey(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to revoke api key",
+		})
+	}
+
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "api key not found",
+		})
+	}
+
+	h.authenticator.Forget(id)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
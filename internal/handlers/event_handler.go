@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/live"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// eventRetry is the reconnection delay suggested to event stream clients,
+// in milliseconds
+const eventRetry = 3000
+
+// streamEventTypes are the event types that can be requested from the
+// event stream
+var streamEventTypes = map[string]bool{
+	models.EventGeofenceEntry: true,
+	models.EventGeofenceExit:  true,
+	models.EventGeofenceDwell: true,
+	models.EventOffRoute:      true,
+	models.EventBackOnRoute:   true,
+	models.EventOverspeed:     true,
+	models.EventOverspeedEnd:  true,
+}
+
+// EventHandler serves the Server-Sent Events stream of fleet alerts
+type EventHandler struct {
+	hub       *live.EventHub
+	keepAlive time.Duration
+}
+
+// NewEventHandler creates a new EventHandler
+func NewEventHandler(hub *live.EventHub, keepAlive time.Duration) *EventHandler {
+	return &EventHandler{hub: hub, keepAlive: keepAlive}
+}
+
+// Stream handles GET /events/stream
+func (h *EventHandler) Stream(c *fiber.Ctx) error {
+	types := splitList(c.Query("types"))
+	for _, t := range types {
+		if !streamEventTypes[t] {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: fmt.Sprintf("unknown event type %q", t),
+			})
+		}
+	}
+
+	filter := live.EventFilter{
+		Types:      toSet(types),
+		VehicleIDs: toSet(splitList(c.Query("vehicles"))),
+	}
+
+	// Browsers send Last-Event-ID when reconnecting; the query parameter
+	// lets a new EventSource resume too, since it can't set headers
+	var lastEventID int64
+	lastID := c.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		id, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil || id < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "invalid Last-Event-ID",
+			})
+		}
+		lastEventID = id
+	}
+
+	client, backlog := h.hub.Subscribe(filter, lastEventID)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer client.Close()
+
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetry); err != nil {
+			return
+		}
+		for i := range backlog {
+			if err := writeEvent(w, &backlog[i]); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(h.keepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			var err error
+
+			select {
+			case <-client.Done():
+				// The client reconnects on its own and resumes from the
+				// last event it received
+				data, _ := json.Marshal(models.ErrorResponse{Error: client.Err().Error()})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				w.Flush()
+				return
+
+			case event := <-client.Events():
+				err = writeEvent(w, &event)
+
+			case <-keepAlive.C:
+				_, err = w.WriteString(": keep-alive\n\n")
+			}
+
+			// A failed flush means the client has gone away
+			if err == nil {
+				err = w.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeEvent writes one event in the Server-Sent Events format
+func writeEvent(w *bufio.Writer, event *live.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+// GeofenceHandler handles HTTP requests for geofence endpoints
+type GeofenceHandler struct {
+	repo    *repository.GeofenceRepository
+	checker *geofence.Checker
+}
+
+// NewGeofenceHandler creates a new GeofenceHandler
+func NewGeofenceHandler(repo *repository.GeofenceRepository, checker *geofence.Checker) *GeofenceHandler {
+	return &GeofenceHandler{repo: repo, checker: checker}
+}
+
+// geofenceRequest is the request body for creating or updating a geofence
+type geofenceRequest struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Radius     float64         `json:"radius"`
+	Buffer     float64         `json:"buffer"`
+	SpeedLimit float64         `json:"speed_limit"`
+	ExpiresAt  *int64          `json:"expires_at"`
+	ExitBuffer *float64        `json:"exit_buffer"`
+	MinPings   *int            `json:"min_pings"`
+	MinSeconds *int64          `json:"min_seconds"`
+}
+
+// CreateGeofence handles POST /geofences
+func (h *GeofenceHandler) CreateGeofence(c *fiber.Ctx) error {
+	var req geofenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "invalid request body",
+		})
+	}
+
+	if req.ID == "" {
+		req.ID = uuid.NewString()
+	}
+
+	if h.checker.HasStaticFence(req.ID) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "geofence id is reserved by a static geofence",
+		})
+	}
+
+	g, fence, err := h.buildGeofence(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if err := h.repo.CreateGeofence(g); err != nil {
+		if errors.Is(err, repository.ErrGeofenceExists) {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error: "geofence already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to create geofence",
+		})
+	}
+
+	h.checker.AddFence(fence)
+
+	return c.Status(fiber.StatusCreated).JSON(g)
+}
+
+// ListGeofences handles GET /geofences
+func (h *GeofenceHandler) ListGeofences(c *fiber.Ctx) error {
+	geofences, err := h.repo.ListGeofences()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to list geofences",
+		})
+	}
+
+	if geofences == nil {
+		geofences = []models.Geofence{}
+	}
+
+	return c.JSON(geofences)
+}
+
+// GetGeofence handles GET /geofences/:id
+func (h *GeofenceHandler) GetGeofence(c *fiber.Ctx) error {
+	g, err := h.repo.GetGeofence(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to get geofence",
+		})
+	}
+
+	if g == nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "geofence not found",
+		})
+	}
+
+	return c.JSON(g)
+}
+
+// UpdateGeofence handles PUT /geofences/:id
+func (h *GeofenceHandler) UpdateGeofence(c *fiber.Ctx) error {
+	var req geofenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "invalid request body",
+		})
+	}
+	// copied because the checker keeps the id after the request buffer is reused
+	req.ID = strings.Clone(c.Params("id"))
+
+	if h.checker.HasStaticFence(req.ID) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "static geofences can't be changed through the API",
+		})
+	}
+
+	g, fence, err := h.buildGeofence(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	found, err := h.repo.UpdateGeofence(g)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to update geofence",
+		})
+	}
+
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "geofence not found",
+		})
+	}
+
+	h.checker.AddFence(fence)
+
+	return c.JSON(g)
+}
+
+// DeleteGeofence handles DELETE /geofences/:id
+func (h *GeofenceHandler) DeleteGeofence(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if h.checker.HasStaticFence(id) {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error: "static geofences can't be changed through the API",
+		})
+	}
+
+	found, err := h.repo.DeleteGeofence(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to delete geofence",
+		})
+	}
+
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "geofence not found",
+		})
+	}
+
+	h.checker.RemoveFence(id)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// buildGeofence validates a request and converts it into a stored geofence
+// and the fence used by the checker
+func (h *GeofenceHandler) buildGeofence(req geofenceRequest) (*models.Geofence, *geofence.Fence, error) {
+	if req.Name == "" {
+		return nil, nil, errors.New("name is required")
+	}
+
+	if len(req.Geometry) == 0 {
+		return nil, nil, errors.New("geometry is required")
+	}
+
+	if req.SpeedLimit < 0 {
+		return nil, nil, errors.New("speed_limit must not be negative")
+	}
+
+	if req.ExitBuffer != nil && *req.ExitBuffer < 0 {
+		return nil, nil, errors.New("exit_buffer must not be negative")
+	}
+
+	if req.MinPings != nil && *req.MinPings < 0 {
+		return nil, nil, errors.New("min_pings must not be negative")
+	}
+
+	if req.MinSeconds != nil && *req.MinSeconds < 0 {
+		return nil, nil, errors.New("min_seconds must not be negative")
+	}
+
+	g := &models.Geofence{
+		ID:         req.ID,
+		Name:       req.Name,
+		Geometry:   req.Geometry,
+		Radius:     req.Radius,
+		Buffer:     req.Buffer,
+		SpeedLimit: req.SpeedLimit,
+		ExpiresAt:  req.ExpiresAt,
+		ExitBuffer: req.ExitBuffer,
+		MinPings:   req.MinPings,
+		MinSeconds: req.MinSeconds,
+	}
+
+	fence, err := h.checker.FenceFromModel(g)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return g, fence, nil
+}
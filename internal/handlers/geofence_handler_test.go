@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+)
+
+func newTestGeofenceHandler(t *testing.T) *GeofenceHandler {
+	t.Helper()
+
+	checker, err := geofence.NewChecker(&config.Config{
+		GeofenceID:        "static",
+		GeofenceName:      "Static",
+		GeofenceLatitude:  -6.2,
+		GeofenceLongitude: 106.8,
+		GeofenceRadius:    50,
+		GeofenceMinPings:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGeofenceHandler(nil, checker)
+}
+
+func TestBuildGeofenceValidation(t *testing.T) {
+	h := newTestGeofenceHandler(t)
+
+	tests := []struct {
+		body    string
+		wantErr string
+	}{
+		{`{"geometry": {"type": "Point", "coordinates": [106.8, -6.2]}, "radius": 50}`, "name is required"},
+		{`{"name": "Halte"}`, "geometry is required"},
+		{`{"name": "Halte", "geometry": {"type": "Point", "coordinates": [106.8, -6.2]}}`, "radius must be positive"},
+		{`{"name": "Halte", "geometry": {"type": "Point", "coordinates": [-6.2, 106.8]}, "radius": 50}`, "invalid latitude"},
+		{`{"name": "Halte", "geometry": {"type": "Circle", "coordinates": [106.8, -6.2]}, "radius": 50}`, "unsupported geometry type"},
+		{`{"name": "Halte", "geometry": {"type": "Point", "coordinates": "106.8,-6.2"}, "radius": 50}`, "invalid Point coordinates"},
+		{`{"name": "Depot", "geometry": {"type": "Polygon", "coordinates": [[[106.8, -6.2], [106.81, -6.2], [106.8, -6.2]]]}}`, "at least 4 positions"},
+		{`{"name": "Depot", "geometry": {"type": "Polygon", "coordinates": []}}`, "outer ring"},
+		{`{"name": "Koridor", "geometry": {"type": "LineString", "coordinates": [[106.8, -6.2], [106.81, -6.2]]}}`, "buffer must be positive"},
+		{`{"name": "Koridor", "geometry": {"type": "LineString", "coordinates": [[106.8, -6.2]]}, "buffer": 30}`, "at least 2 positions"},
+		{`{"name": "Halte", "geometry": {"type": "Point", "coordinates": [106.8, -6.2]}, "radius": 50, "speed_limit": -1}`, "speed_limit must not be negative"},
+		{`{"name": "Halte", "geometry": {"type": "Point", "coordinates": [106.8, -6.2]}, "radius": 50, "min_pings": -1}`, "min_pings must not be negative"},
+	}
+
+	for _, tt := range tests {
+		var req geofenceRequest
+		if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+			t.Fatalf("%s: %v", tt.body, err)
+		}
+
+		_, _, err := h.buildGeofence(req)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tt.body, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildGeofence(t *testing.T) {
+	h := newTestGeofenceHandler(t)
+
+	var req geofenceRequest
+	body := `{
+		"id": "depot-cawang",
+		"name": "Depot Cawang",
+		"geometry": {"type": "Polygon", "coordinates": [
+			[[106.86, -6.25], [106.88, -6.25], [106.88, -6.23], [106.86, -6.23], [106.86, -6.25]],
+			[[106.869, -6.241], [106.871, -6.241], [106.871, -6.239], [106.869, -6.239], [106.869, -6.241]]
+		]},
+		"min_seconds": 30
+	}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	g, fence, err := h.buildGeofence(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.ID != "depot-cawang" || fence.ID != "depot-cawang" || fence.Name != "Depot Cawang" {
+		t.Errorf("got geofence %q and fence %q/%q", g.ID, fence.ID, fence.Name)
+	}
+	polygon, ok := fence.Shape.(*geofence.Polygon)
+	if !ok || len(polygon.Rings) != 2 {
+		t.Fatalf("got shape %#v, want a polygon with one hole", fence.Shape)
+	}
+	if polygon.Contains(-6.24, 106.87) || !polygon.Contains(-6.245, 106.865) {
+		t.Error("hole not applied to the parsed polygon")
+	}
+
+	// Settings missing from the request fall back to the checker defaults
+	if fence.Hysteresis.MinPings != 2 || fence.Hysteresis.MinSeconds != 30 {
+		t.Errorf("got hysteresis %+v, want the default min_pings and min_seconds 30", fence.Hysteresis)
+	}
+}
+
+func TestCreateGeofenceStaticConflict(t *testing.T) {
+	h := newTestGeofenceHandler(t)
+
+	app := fiber.New()
+	app.Post("/geofences", h.CreateGeofence)
+	app.Put("/geofences/:id", h.UpdateGeofence)
+
+	body := `{"id": "static", "name": "Static", "geometry": {"type": "Point", "coordinates": [106.8, -6.2]}, "radius": 50}`
+	for _, r := range []struct{ method, path string }{
+		{fiber.MethodPost, "/geofences"},
+		{fiber.MethodPut, "/geofences/static"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusConflict {
+			t.Errorf("%s %s: got status %d, want %d", r.method, r.path, resp.StatusCode, fiber.StatusConflict)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthHandler handles the health check endpoint
+type HealthHandler struct {
+	queues map[string]func() int
+}
+
+// NewHealthHandler creates a new HealthHandler. queues maps a queue name
+// to a function returning its current depth.
+func NewHealthHandler(queues map[string]func() int) *HealthHandler {
+	return &HealthHandler{queues: queues}
+}
+
+// Health handles GET /health
+func (h *HealthHandler) Health(c *fiber.Ctx) error {
+	depths := make(fiber.Map, len(h.queues))
+	for name, depth := range h.queues {
+		depths[name] = depth()
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "healthy",
+		"queues": depths,
+	})
+}
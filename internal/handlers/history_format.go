@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Location history formats
+const (
+	formatJSON    = "json"
+	formatNDJSON  = "ndjson"
+	formatGeoJSON = "geojson"
+	formatGPX     = "gpx"
+	formatKML     = "kml"
+)
+
+// historyContentTypes maps each history format to its content type, in
+// the order offered during content negotiation
+var historyContentTypes = []struct {
+	format      string
+	contentType string
+}{
+	{formatJSON, "application/json"},
+	{formatNDJSON, "application/x-ndjson"},
+	{formatGeoJSON, "application/geo+json"},
+	{formatGPX, "application/gpx+xml"},
+	{formatKML, "application/vnd.google-earth.kml+xml"},
+}
+
+// locationSource iterates over the history of one vehicle in timestamp
+// order. It reads the history from the database, so encoders call it once.
+type locationSource func(fn func(loc *models.VehicleLocation) error) error
+
+// historyEncoder writes the history of one or more vehicles to a response
+// stream without holding it in memory
+type historyEncoder interface {
+	begin(w *bufio.Writer) error
+	vehicle(w *bufio.Writer, vehicleID string, each locationSource) error
+	end(w *bufio.Writer) error
+}
+
+// newHistoryEncoder creates an encoder for the given format. Exports of
+// several vehicles are wrapped in a collection where the format needs it.
+func newHistoryEncoder(format string, multi bool) historyEncoder {
+	switch format {
+	case formatNDJSON:
+		return &ndjsonEncoder{}
+	case formatGeoJSON:
+		return &geojsonEncoder{multi: multi}
+	case formatGPX:
+		return &gpxEncoder{}
+	case formatKML:
+		return &kmlEncoder{}
+	default:
+		return &jsonArrayEncoder{}
+	}
+}
+
+// jsonArrayEncoder writes the history as a single JSON array of locations
+type jsonArrayEncoder struct {
+	started bool
+}
+
+func (e *jsonArrayEncoder) begin(w *bufio.Writer) error {
+	return w.WriteByte('[')
+}
+
+func (e *jsonArrayEncoder) vehicle(w *bufio.Writer, vehicleID string, each locationSource) error {
+	return each(func(loc *models.VehicleLocation) error {
+		if e.started {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		e.started = true
+
+		return writeJSON(w, loc)
+	})
+}
+
+func (e *jsonArrayEncoder) end(w *bufio.Writer) error {
+	return w.WriteByte(']')
+}
+
+// ndjsonEncoder writes the history as newline-delimited JSON, one
+// location per line
+type ndjsonEncoder struct{}
+
+func (e *ndjsonEncoder) begin(w *bufio.Writer) error {
+	return nil
+}
+
+func (e *ndjsonEncoder) vehicle(w *bufio.Writer, vehicleID string, each locationSource) error {
+	return each(func(loc *models.VehicleLocation) error {
+		if err := writeJSON(w, loc); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	})
+}
+
+func (e *ndjsonEncoder) end(w *bufio.Writer) error {
+	return nil
+}
+
+// pointProperties are the per-point properties of a GeoJSON track
+type pointProperties struct {
+	Timestamp int64    `json:"timestamp"`
+	Speed     *float64 `json:"speed,omitempty"`
+	Heading   *float64 `json:"heading,omitempty"`
+	HDOP      *float64 `json:"hdop,omitempty"`
+	Accuracy  *float64 `json:"accuracy,omitempty"`
+	Odometer  *float64 `json:"odometer,omitempty"`
+	Ignition  *bool    `json:"ignition,omitempty"`
+	DoorOpen  *bool    `json:"door_open,omitempty"`
+}
+
+// geojsonEncoder writes each vehicle as a GeoJSON Feature with a
+// LineString track and the per-point properties in properties.points, in
+// the same order as the coordinates. Several vehicles are wrapped in a
+// FeatureCollection.
+//
+// Properties are written first while the coordinates are spooled, so the
+// geometry type can follow the number of points: no geometry for an empty
+// track, a Point for a single fix.
+type geojsonEncoder struct {
+	multi   bool
+	started bool
+}
+
+func (e *geojsonEncoder) begin(w *bufio.Writer) error {
+	if !e.multi {
+		return nil
+	}
+	_, err := w.WriteString(`{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (e *geojsonEncoder) vehicle(w *bufio.Writer, vehicleID string, each locationSource) error {
+	if e.started {
+		if err := w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.started = true
+
+	id, err := json.Marshal(vehicleID)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, `{"type":"Feature","properties":{"vehicle_id":%s,"points":[`, id); err != nil {
+		return err
+	}
+
+	coords, err := newSpool()
+	if err != nil {
+		return err
+	}
+	defer coords.close()
+
+	count := 0
+	err = each(func(loc *models.VehicleLocation) error {
+		if count > 0 {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+			if err := coords.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		count++
+
+		if _, err := fmt.Fprintf(coords, "[%s,%s]", formatCoord(loc.Longitude), formatCoord(loc.Latitude)); err != nil {
+			return err
+		}
+
+		return writeJSON(w, pointProperties{
+			Timestamp: loc.Timestamp,
+			Speed:     loc.Speed,
+			Heading:   loc.Heading,
+			HDOP:      loc.HDOP,
+			Accuracy:  loc.Accuracy,
+			Odometer:  loc.Odometer,
+			Ignition:  loc.Ignition,
+			DoorOpen:  loc.DoorOpen,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	switch count {
+	case 0:
+		_, err = w.WriteString(`]},"geometry":null}`)
+		return err
+	case 1:
+		_, err = w.WriteString(`]},"geometry":{"type":"Point","coordinates":`)
+	default:
+		_, err = w.WriteString(`]},"geometry":{"type":"LineString","coordinates":[`)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := coords.copyTo(w); err != nil {
+		return err
+	}
+
+	if count == 1 {
+		_, err = w.WriteString(`}}`)
+	} else {
+		_, err = w.WriteString(`]}}`)
+	}
+	return err
+}
+
+func (e *geojsonEncoder) end(w *bufio.Writer) error {
+	if !e.multi {
+		return nil
+	}
+	_, err := w.WriteString(`]}`)
+	return err
+}
+
+// gpxEncoder writes the history as a GPX 1.1 file with one track per
+// vehicle. Speed and heading are written with the Garmin TrackPointExtension.
+type gpxEncoder struct{}
+
+func (e *gpxEncoder) begin(w *bufio.Writer) error {
+	_, err := w.WriteString(xml.Header +
+		`<gpx version="1.1" creator="Fleet Management API"` +
+		` xmlns="http://www.topografix.com/GPX/1/1"` +
+		` xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2">` + "\n")
+	return err
+}
+
+func (e *gpxEncoder) vehicle(w *bufio.Writer, vehicleID string, each locationSource) error {
+	if _, err := w.WriteString("<trk><name>"); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(w, []byte(vehicleID)); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("</name><trkseg>\n"); err != nil {
+		return err
+	}
+
+	err := each(func(loc *models.VehicleLocation) error {
+		if _, err := fmt.Fprintf(w, `<trkpt lat="%s" lon="%s"><time>%s</time>`,
+			formatCoord(loc.Latitude), formatCoord(loc.Longitude), formatTime(loc.Timestamp)); err != nil {
+			return err
+		}
+
+		if loc.HDOP != nil {
+			if _, err := fmt.Fprintf(w, "<hdop>%s</hdop>", formatCoord(*loc.HDOP)); err != nil {
+				return err
+			}
+		}
+
+		if loc.Speed != nil || loc.Heading != nil {
+			if _, err := w.WriteString("<extensions><gpxtpx:TrackPointExtension>"); err != nil {
+				return err
+			}
+			if loc.Speed != nil {
+				// GPX speeds are in meters per second
+				if _, err := fmt.Fprintf(w, "<gpxtpx:speed>%s</gpxtpx:speed>", formatCoord(*loc.Speed/3.6)); err != nil {
+					return err
+				}
+			}
+			if loc.Heading != nil {
+				if _, err := fmt.Fprintf(w, "<gpxtpx:course>%s</gpxtpx:course>", formatCoord(*loc.Heading)); err != nil {
+					return err
+				}
+			}
+			if _, err := w.WriteString("</gpxtpx:TrackPointExtension></extensions>"); err != nil {
+				return err
+			}
+		}
+
+		_, err := w.WriteString("</trkpt>\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString("</trkseg></trk>\n")
+	return err
+}
+
+func (e *gpxEncoder) end(w *bufio.Writer) error {
+	_, err := w.WriteString("</gpx>\n")
+	return err
+}
+
+// kmlEncoder writes the history as a KML document with one gx:Track
+// placemark per vehicle, which Google Earth can replay over time. A track
+// lists every timestamp before the coordinates, so the coordinates are
+// spooled while the timestamps are written.
+type kmlEncoder struct{}
+
+func (e *kmlEncoder) begin(w *bufio.Writer) error {
+	_, err := w.WriteString(xml.Header +
+		`<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">` +
+		"\n<Document><name>Vehicle history</name>\n")
+	return err
+}
+
+func (e *kmlEncoder) vehicle(w *bufio.Writer, vehicleID string, each locationSource) error {
+	if _, err := w.WriteString("<Placemark><name>"); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(w, []byte(vehicleID)); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("</name><gx:Track>\n"); err != nil {
+		return err
+	}
+
+	coords, err := newSpool()
+	if err != nil {
+		return err
+	}
+	defer coords.close()
+
+	err = each(func(loc *models.VehicleLocation) error {
+		if _, err := fmt.Fprintf(coords, "<gx:coord>%s %s 0</gx:coord>\n",
+			formatCoord(loc.Longitude), formatCoord(loc.Latitude)); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintf(w, "<when>%s</when>\n", formatTime(loc.Timestamp))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := coords.copyTo(w); err != nil {
+		return err
+	}
+
+	_, err = w.WriteString("</gx:Track></Placemark>\n")
+	return err
+}
+
+func (e *kmlEncoder) end(w *bufio.Writer) error {
+	_, err := w.WriteString("</Document>\n</kml>\n")
+	return err
+}
+
+// spool buffers the part of a track that has to follow another in a
+// temporary file, so the history is read once and memory use doesn't grow
+// with its length
+type spool struct {
+	*bufio.Writer
+	file *os.File
+}
+
+// newSpool creates an empty spool backed by a new temporary file
+func newSpool() (*spool, error) {
+	file, err := os.CreateTemp("", "history-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	return &spool{Writer: bufio.NewWriter(file), file: file}, nil
+}
+
+// copyTo writes everything spooled so far to w
+func (s *spool) copyTo(w *bufio.Writer) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, s.file)
+	return err
+}
+
+// close removes the spool file
+func (s *spool) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
+
+// historyFilename names a downloaded history export
+func historyFilename(vehicleIDs []string, format string) string {
+	name := "fleet"
+	if len(vehicleIDs) == 1 {
+		name = vehicleIDs[0]
+	}
+	return fmt.Sprintf("%s-history.%s", name, format)
+}
+
+// writeJSON writes the JSON encoding of v without a trailing newline
+func writeJSON(w *bufio.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// formatCoord formats a number with the shortest exact representation
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatTime formats a unix timestamp as an RFC 3339 UTC time
+func formatTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// vehicleTrack is the history of one vehicle fed to an encoder
+type vehicleTrack struct {
+	id        string
+	locations []models.VehicleLocation
+}
+
+// encodeHistory runs an encoder over the tracks the way streamHistory
+// does and returns the output. Spool files go to a temporary directory
+// that must be empty again afterwards.
+func encodeHistory(t *testing.T, format string, tracks ...vehicleTrack) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	enc := newHistoryEncoder(format, len(tracks) > 1)
+
+	if err := enc.begin(w); err != nil {
+		t.Fatal(err)
+	}
+	for _, track := range tracks {
+		each := func(fn func(loc *models.VehicleLocation) error) error {
+			for i := range track.locations {
+				if err := fn(&track.locations[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := enc.vehicle(w, track.id, each); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.end(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if left, _ := os.ReadDir(tmp); len(left) > 0 {
+		t.Errorf("%d spool files left behind", len(left))
+	}
+	return buf.String()
+}
+
+func fixes(n int) []models.VehicleLocation {
+	speed := 36.0
+	locs := make([]models.VehicleLocation, n)
+	for i := range locs {
+		locs[i] = models.VehicleLocation{
+			VehicleID: "B1234XYZ",
+			Latitude:  -6.2 + float64(i)*0.001,
+			Longitude: 106.8,
+			Timestamp: 1715000000 + int64(i)*10,
+			Speed:     &speed,
+		}
+	}
+	return locs
+}
+
+type geojsonFeature struct {
+	Type       string `json:"type"`
+	Properties struct {
+		VehicleID string            `json:"vehicle_id"`
+		Points    []pointProperties `json:"points"`
+	} `json:"properties"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func TestGeoJSONGeometryFollowsPointCount(t *testing.T) {
+	tests := []struct {
+		fixes    int
+		geometry string // empty for a null geometry
+		coords   string
+	}{
+		{0, "", ""},
+		{1, "Point", "[106.8,-6.2]"},
+		{3, "LineString", "[[106.8,-6.2],[106.8,-6.199],[106.8,-6.198]]"},
+	}
+
+	for _, tt := range tests {
+		out := encodeHistory(t, formatGeoJSON, vehicleTrack{"B1234XYZ", fixes(tt.fixes)})
+
+		var f geojsonFeature
+		if err := json.Unmarshal([]byte(out), &f); err != nil {
+			t.Fatalf("%d fixes: invalid GeoJSON %s: %v", tt.fixes, out, err)
+		}
+		if f.Type != "Feature" || f.Properties.VehicleID != "B1234XYZ" || len(f.Properties.Points) != tt.fixes {
+			t.Errorf("%d fixes: got %s", tt.fixes, out)
+		}
+
+		switch {
+		case tt.geometry == "" && f.Geometry != nil:
+			t.Errorf("%d fixes: got geometry %s, want null", tt.fixes, f.Geometry.Type)
+		case tt.geometry != "" && (f.Geometry == nil || f.Geometry.Type != tt.geometry || string(f.Geometry.Coordinates) != tt.coords):
+			t.Errorf("%d fixes: got %s, want a %s with %s", tt.fixes, out, tt.geometry, tt.coords)
+		}
+	}
+}
+
+func TestGeoJSONFeatureCollection(t *testing.T) {
+	out := encodeHistory(t, formatGeoJSON,
+		vehicleTrack{"B1", fixes(2)},
+		vehicleTrack{"B2", nil},
+	)
+
+	var fc struct {
+		Type     string           `json:"type"`
+		Features []geojsonFeature `json:"features"`
+	}
+	if err := json.Unmarshal([]byte(out), &fc); err != nil {
+		t.Fatalf("invalid GeoJSON %s: %v", out, err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 2 ||
+		fc.Features[0].Properties.VehicleID != "B1" || fc.Features[1].Geometry != nil {
+		t.Errorf("got %s", out)
+	}
+}
+
+func TestGPXSpeedInMetersPerSecond(t *testing.T) {
+	out := encodeHistory(t, formatGPX, vehicleTrack{"B1234<XYZ>", fixes(2)})
+
+	var gpx struct {
+		Tracks []struct {
+			Name   string `xml:"name"`
+			Points []struct {
+				Lat   float64 `xml:"lat,attr"`
+				Time  string  `xml:"time"`
+				Speed float64 `xml:"extensions>TrackPointExtension>speed"`
+			} `xml:"trkseg>trkpt"`
+		} `xml:"trk"`
+	}
+	if err := xml.Unmarshal([]byte(out), &gpx); err != nil {
+		t.Fatalf("invalid GPX %s: %v", out, err)
+	}
+
+	if len(gpx.Tracks) != 1 || gpx.Tracks[0].Name != "B1234<XYZ>" || len(gpx.Tracks[0].Points) != 2 {
+		t.Fatalf("got %s", out)
+	}
+	pt := gpx.Tracks[0].Points[1]
+	if math.Abs(pt.Speed-10) > 1e-9 {
+		t.Errorf("got speed %v m/s for 36 km/h, want 10", pt.Speed)
+	}
+	if pt.Time != "2024-05-06T12:53:30Z" || pt.Lat != -6.199 {
+		t.Errorf("got point %+v", pt)
+	}
+}
+
+func TestKMLTrackWritesTimestampsBeforeCoords(t *testing.T) {
+	out := encodeHistory(t, formatKML, vehicleTrack{"B1", fixes(3)})
+
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid KML: %v", err)
+		}
+	}
+	if whens, coords := strings.Count(out, "<when>"), strings.Count(out, "<gx:coord>"); whens != 3 || coords != 3 {
+		t.Errorf("got %d when and %d gx:coord elements, want 3 each", whens, coords)
+	}
+	if strings.Index(out, "<gx:coord>") < strings.LastIndex(out, "<when>") {
+		t.Error("coordinates written before every timestamp")
+	}
+}
+
+func TestJSONAndNDJSONEmptyTrack(t *testing.T) {
+	if out := encodeHistory(t, formatJSON, vehicleTrack{"B1", nil}); out != "[]" {
+		t.Errorf("json: got %q, want []", out)
+	}
+	if out := encodeHistory(t, formatNDJSON, vehicleTrack{"B1", nil}); out != "" {
+		t.Errorf("ndjson: got %q, want nothing", out)
+	}
+
+	out := encodeHistory(t, formatNDJSON, vehicleTrack{"B1", fixes(2)}, vehicleTrack{"B2", fixes(1)})
+	if lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n"); len(lines) != 3 {
+		t.Errorf("ndjson: got %d lines, want 3", len(lines))
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/live"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+const (
+	// liveWriteWait is the time allowed to write one message to a client
+	liveWriteWait = 10 * time.Second
+
+	// liveFilterKey is the Fiber local holding the filter parsed before
+	// the connection is upgraded
+	liveFilterKey = "live_filter"
+)
+
+// subscription is the message a live feed client sends to change its
+// filter. It replaces the whole filter; empty fields match everything.
+type subscription struct {
+	Vehicles []string  `json:"vehicles"`
+	Routes   []string  `json:"routes"`
+	BBox     []float64 `json:"bbox"` // minLon, minLat, maxLon, maxLat
+}
+
+// LiveHandler serves the real-time WebSocket feed of vehicle locations
+type LiveHandler struct {
+	hub          *live.LocationHub
+	pingInterval time.Duration
+}
+
+// NewLiveHandler creates a new LiveHandler
+func NewLiveHandler(hub *live.LocationHub, pingInterval time.Duration) *LiveHandler {
+	return &LiveHandler{hub: hub, pingInterval: pingInterval}
+}
+
+// Upgrade rejects plain HTTP requests to the feed and parses the initial
+// filter from the vehicles, routes and bbox query parameters
+func (h *LiveHandler) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(models.ErrorResponse{
+			Error: "websocket upgrade required",
+		})
+	}
+
+	sub := subscription{
+		Vehicles: splitList(c.Query("vehicles")),
+		Routes:   splitList(c.Query("routes")),
+	}
+	if bbox := c.Query("bbox"); bbox != "" {
+		bounds, err := parseBBox(bbox)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		sub.BBox = []float64{bounds.MinLon, bounds.MinLat, bounds.MaxLon, bounds.MaxLat}
+	}
+
+	filter, err := sub.filter()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	c.Locals(liveFilterKey, filter)
+	return c.Next()
+}
+
+// Locations handles GET /ws/locations
+func (h *LiveHandler) Locations() fiber.Handler {
+	return websocket.New(h.serve)
+}
+
+// serve streams matching locations to one client until it disconnects or
+// is dropped for falling behind
+func (h *LiveHandler) serve(conn *websocket.Conn) {
+	filter, _ := conn.Locals(liveFilterKey).(live.Filter)
+
+	client := h.hub.Subscribe(filter)
+	defer client.Close()
+
+	// Read subscription changes; the connection allows one concurrent
+	// reader and one writer, so replies go through the write loop
+	closed := make(chan struct{})
+	replies := make(chan models.ErrorResponse, 1)
+	go func() {
+		defer close(closed)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var sub subscription
+			if err := json.Unmarshal(msg, &sub); err != nil {
+				reply(replies, "invalid subscription message")
+				continue
+			}
+
+			filter, err := sub.filter()
+			if err != nil {
+				reply(replies, err.Error())
+				continue
+			}
+			client.SetFilter(filter)
+		}
+	}()
+
+	ping := time.NewTicker(h.pingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-closed:
+			return
+
+		case <-client.Done():
+			code := websocket.CloseGoingAway
+			if client.Err() == live.ErrSlowConsumer {
+				code = websocket.ClosePolicyViolation
+			}
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(code, client.Err().Error()),
+				time.Now().Add(liveWriteWait))
+			return
+
+		case loc := <-client.Locations():
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			err = conn.WriteJSON(loc)
+
+		case msg := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			err = conn.WriteJSON(msg)
+
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait))
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// filter converts a subscription message into a hub filter
+func (s *subscription) filter() (live.Filter, error) {
+	filter := live.Filter{
+		VehicleIDs: toSet(s.Vehicles),
+		RouteIDs:   toSet(s.Routes),
+	}
+
+	if len(s.BBox) > 0 {
+		if len(s.BBox) != 4 {
+			return live.Filter{}, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+
+		bounds, err := newBBox([4]float64(s.BBox))
+		if err != nil {
+			return live.Filter{}, err
+		}
+		filter.Bounds = bounds
+	}
+
+	return filter, nil
+}
+
+// reply queues an error message for the client, dropping it when one is
+// already waiting
+func reply(replies chan<- models.ErrorResponse, msg string) {
+	select {
+	case replies <- models.ErrorResponse{Error: msg}:
+	default:
+	}
+}
+
+// toSet converts a list into a set, nil when the list is empty
+func toSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+// RouteHandler handles HTTP requests for vehicle route assignments
+type RouteHandler struct {
+	repo     *repository.RouteRepository
+	checker  *geofence.Checker
+	detector *geofence.DeviationDetector
+}
+
+// NewRouteHandler creates a new RouteHandler
+func NewRouteHandler(repo *repository.RouteRepository, checker *geofence.Checker, detector *geofence.DeviationDetector) *RouteHandler {
+	return &RouteHandler{repo: repo, checker: checker, detector: detector}
+}
+
+// assignRouteRequest is the request body for assigning a route
+type assignRouteRequest struct {
+	RouteID string `json:"route_id"`
+}
+
+// AssignRoute handles PUT /vehicles/:vehicle_id/route
+func (h *RouteHandler) AssignRoute(c *fiber.Ctx) error {
+	// copied because the detector keeps the id after the request buffer is reused
+	vehicleID := strings.Clone(c.Params("vehicle_id"))
+
+	var req assignRouteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "invalid request body",
+		})
+	}
+
+	if req.RouteID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "route_id is required",
+		})
+	}
+
+	fence := h.checker.Fence(req.RouteID)
+	if fence == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "route not found",
+		})
+	}
+
+	if _, ok := fence.Shape.(*geofence.Corridor); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "route_id must reference a corridor geofence",
+		})
+	}
+
+	assignment, err := h.repo.AssignRoute(vehicleID, req.RouteID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to assign route",
+		})
+	}
+
+	h.detector.Assign(vehicleID, req.RouteID)
+
+	return c.JSON(assignment)
+}
+
+// GetRoute handles GET /vehicles/:vehicle_id/route
+func (h *RouteHandler) GetRoute(c *fiber.Ctx) error {
+	assignment, err := h.repo.GetAssignment(c.Params("vehicle_id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to get route assignment",
+		})
+	}
+
+	if assignment == nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "route assignment not found",
+		})
+	}
+
+	return c.JSON(assignment)
+}
+
+// UnassignRoute handles DELETE /vehicles/:vehicle_id/route
+func (h *RouteHandler) UnassignRoute(c *fiber.Ctx) error {
+	vehicleID := strings.Clone(c.Params("vehicle_id"))
+
+	found, err := h.repo.UnassignRoute(vehicleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to unassign route",
+		})
+	}
+
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "route assignment not found",
+		})
+	}
+
+	h.detector.Assign(vehicleID, "")
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/trips"
+)
+
+// maxSummaryRange is the longest time range a summary can cover, in
+// seconds, since it reads every location in the range
+const maxSummaryRange = 31 * 24 * 3600
+
+// TripHandler handles HTTP requests for vehicle trips and movement
+// summaries
+type TripHandler struct {
+	repo       *repository.TripRepository
+	summarizer *trips.Summarizer
+}
+
+// NewTripHandler creates a new TripHandler
+func NewTripHandler(repo *repository.TripRepository, summarizer *trips.Summarizer) *TripHandler {
+	return &TripHandler{repo: repo, summarizer: summarizer}
+}
+
+// ListTrips handles GET /vehicles/:vehicle_id/trips
+func (h *TripHandler) ListTrips(c *fiber.Ctx) error {
+	vehicleID := c.Params("vehicle_id")
+	if vehicleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "vehicle_id is required",
+		})
+	}
+
+	start, end, err := parseTimeRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	list, err := h.repo.ListTrips(vehicleID, start, end)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to get trips",
+		})
+	}
+
+	return c.JSON(list)
+}
+
+// GetSummary handles GET /vehicles/:vehicle_id/summary
+func (h *TripHandler) GetSummary(c *fiber.Ctx) error {
+	vehicleID := c.Params("vehicle_id")
+	if vehicleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "vehicle_id is required",
+		})
+	}
+
+	start, end, err := parseTimeRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if end-start > maxSummaryRange {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: fmt.Sprintf("time range must not exceed %d days", maxSummaryRange/(24*3600)),
+		})
+	}
+
+	summary, err := h.summarizer.Summarize(vehicleID, start, end)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to get vehicle summary",
+		})
+	}
+
+	return c.JSON(summary)
+}
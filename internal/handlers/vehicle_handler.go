@@ -1,22 +1,204 @@
 package handlers
 
 import (
+	"bufio"
+	"fmt"
+	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
 )
 
+// locationFields maps the selectable location fields to their values
+var locationFields = map[string]func(loc *models.VehicleLocation) interface{}{
+	"vehicle_id": func(loc *models.VehicleLocation) interface{} { return loc.VehicleID },
+	"latitude":   func(loc *models.VehicleLocation) interface{} { return loc.Latitude },
+	"longitude":  func(loc *models.VehicleLocation) interface{} { return loc.Longitude },
+	"timestamp":  func(loc *models.VehicleLocation) interface{} { return loc.Timestamp },
+	"speed":      func(loc *models.VehicleLocation) interface{} { return optional(loc.Speed) },
+	"heading":    func(loc *models.VehicleLocation) interface{} { return optional(loc.Heading) },
+	"hdop":       func(loc *models.VehicleLocation) interface{} { return optional(loc.HDOP) },
+	"accuracy":   func(loc *models.VehicleLocation) interface{} { return optional(loc.Accuracy) },
+	"odometer":   func(loc *models.VehicleLocation) interface{} { return optional(loc.Odometer) },
+	"ignition":   func(loc *models.VehicleLocation) interface{} { return optional(loc.Ignition) },
+	"door_open":  func(loc *models.VehicleLocation) interface{} { return optional(loc.DoorOpen) },
+}
+
+const (
+	// defaultNearbyLimit is the number of vehicles /vehicles/nearby
+	// returns when no limit is given
+	defaultNearbyLimit = 50
+
+	// maxNearbyRadius caps the radius of /vehicles/nearby in meters
+	maxNearbyRadius = 50000
+
+	// defaultHistoryPageSize and maxHistoryPageSize bound the locations
+	// returned per page of paginated history
+	defaultHistoryPageSize = 1000
+	maxHistoryPageSize     = 10000
+
+	// maxExportVehicles caps the vehicles in one multi-vehicle export
+	maxExportVehicles = 100
+
+	// historyFlushEvery is the number of streamed locations written
+	// between flushes to the client
+	historyFlushEvery = 500
+)
+
 // VehicleHandler handles HTTP requests for vehicle endpoints
 type VehicleHandler struct {
-	repo *repository.VehicleRepository
+	repo    *repository.VehicleRepository
+	checker *geofence.Checker
+	points  *geofence.PointIndex
 }
 
 // NewVehicleHandler creates a new VehicleHandler
-func NewVehicleHandler(repo *repository.VehicleRepository) *VehicleHandler {
-	return &VehicleHandler{repo: repo}
+func NewVehicleHandler(repo *repository.VehicleRepository, checker *geofence.Checker, points *geofence.PointIndex) *VehicleHandler {
+	return &VehicleHandler{repo: repo, checker: checker, points: points}
+}
+
+// ListWithin handles GET /vehicles/within?bbox=minLon,minLat,maxLon,maxLat
+func (h *VehicleHandler) ListWithin(c *fiber.Ctx) error {
+	bounds, err := parseBBox(c.Query("bbox"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.JSON(freshOnly(h.points.Within(*bounds), maxAge))
+}
+
+// ListNearby handles GET /vehicles/nearby?lat=&lon=&radius=&limit=
+func (h *VehicleHandler) ListNearby(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil || !validCoordinate(lat, lon) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "valid lat and lon query parameters are required",
+		})
+	}
+
+	radius, err := strconv.ParseFloat(c.Query("radius"), 64)
+	if err != nil || !(radius > 0 && radius <= maxNearbyRadius) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: fmt.Sprintf("radius must be between 0 and %d meters", maxNearbyRadius),
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultNearbyLimit)
+	if limit <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "limit must be positive",
+		})
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// Stale vehicles are filtered after the query, so ask for every match
+	// and apply the limit afterwards
+	vehicles := freshOnly(h.points.Nearby(lat, lon, radius, 0), maxAge)
+	if len(vehicles) > limit {
+		vehicles = vehicles[:limit]
+	}
+
+	return c.JSON(vehicles)
+}
+
+// ListLocations handles GET /vehicles/locations
+func (h *VehicleHandler) ListLocations(c *fiber.Ctx) error {
+	filter := repository.LatestLocationFilter{
+		VehicleIDs: splitList(c.Query("ids")),
+		RouteID:    c.Query("route"),
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// Fresh vehicles by default, only the silent ones with stale=true
+	stale := c.QueryBool("stale")
+	if maxAge > 0 {
+		cutoff := time.Now().Unix() - maxAge
+		if stale {
+			filter.UpdatedBefore = cutoff
+		} else {
+			filter.UpdatedAfter = cutoff
+		}
+	} else if stale {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "stale requires max_age",
+		})
+	}
+
+	var depot *geofence.Fence
+	if depotID := c.Query("depot"); depotID != "" {
+		if depot = h.checker.Fence(depotID); depot == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "unknown depot geofence: " + depotID,
+			})
+		}
+	}
+
+	fields := splitList(c.Query("fields"))
+	for _, field := range fields {
+		if _, ok := locationFields[field]; !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "unknown field: " + field,
+			})
+		}
+	}
+
+	locations, err := h.repo.ListLatestLocations(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: "failed to get locations",
+		})
+	}
+
+	if depot != nil {
+		inside := locations[:0]
+		for _, loc := range locations {
+			if depot.Shape.Contains(loc.Latitude, loc.Longitude) {
+				inside = append(inside, loc)
+			}
+		}
+		locations = inside
+	}
+
+	if len(fields) == 0 {
+		if locations == nil {
+			locations = []models.VehicleLocation{}
+		}
+		return c.JSON(locations)
+	}
+
+	selected := make([]fiber.Map, len(locations))
+	for i := range locations {
+		selected[i] = selectFields(&locations[i], fields)
+	}
+	return c.JSON(selected)
 }
 
 // GetLatestLocation handles GET /vehicles/:vehicle_id/location
@@ -46,52 +228,300 @@ func (h *VehicleHandler) GetLatestLocation(c *fiber.Ctx) error {
 
 // GetLocationHistory handles GET /vehicles/:vehicle_id/history
 func (h *VehicleHandler) GetLocationHistory(c *fiber.Ctx) error {
-	vehicleID := c.Params("vehicle_id")
+	// copied because the streamed body is written after the handler returns
+	vehicleID := strings.Clone(c.Params("vehicle_id"))
 	if vehicleID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error: "vehicle_id is required",
 		})
 	}
 
-	startStr := c.Query("start")
-	endStr := c.Query("end")
-
-	if startStr == "" || endStr == "" {
+	start, end, err := parseTimeRange(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "start and end query parameters are required",
+			Error: err.Error(),
 		})
 	}
 
-	start, err := strconv.ParseInt(startStr, 10, 64)
-	if err != nil {
+	format := historyFormat(c)
+	if format == "" {
+		return c.Status(fiber.StatusNotAcceptable).JSON(models.ErrorResponse{
+			Error: "unsupported history format",
+		})
+	}
+
+	if c.Query("limit") != "" || c.Query("cursor") != "" {
+		if format != formatJSON {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "cursor pagination is only supported for json",
+			})
+		}
+		return h.getHistoryPage(c, vehicleID, start, end)
+	}
+
+	return h.streamHistory(c, format, []string{vehicleID}, start, end)
+}
+
+// ExportHistory handles GET /vehicles/history?ids=&start=&end= and
+// streams the history of several vehicles in one response
+func (h *VehicleHandler) ExportHistory(c *fiber.Ctx) error {
+	vehicleIDs := splitList(c.Query("ids"))
+	if len(vehicleIDs) == 0 || len(vehicleIDs) > maxExportVehicles {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "invalid start timestamp",
+			Error: fmt.Sprintf("ids must list between 1 and %d vehicles", maxExportVehicles),
 		})
 	}
 
-	end, err := strconv.ParseInt(endStr, 10, 64)
+	start, end, err := parseTimeRange(c)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "invalid end timestamp",
+			Error: err.Error(),
 		})
 	}
 
-	if start > end {
+	format := historyFormat(c)
+	if format == "" {
+		return c.Status(fiber.StatusNotAcceptable).JSON(models.ErrorResponse{
+			Error: "unsupported history format",
+		})
+	}
+
+	return h.streamHistory(c, format, vehicleIDs, start, end)
+}
+
+// getHistoryPage responds with one page of location history
+func (h *VehicleHandler) getHistoryPage(c *fiber.Ctx, vehicleID string, start, end int64) error {
+	limit := c.QueryInt("limit", defaultHistoryPageSize)
+	if limit <= 0 || limit > maxHistoryPageSize {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
-			Error: "start timestamp must be less than or equal to end timestamp",
+			Error: fmt.Sprintf("limit must be between 1 and %d", maxHistoryPageSize),
 		})
 	}
 
-	locations, err := h.repo.GetLocationHistory(vehicleID, start, end)
+	var cursor *repository.HistoryCursor
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		var err error
+		if cursor, err = repository.DecodeHistoryCursor(cursorStr); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "invalid cursor",
+			})
+		}
+	}
+
+	locations, next, err := h.repo.GetLocationHistoryPage(vehicleID, start, end, cursor, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error: "failed to get location history",
 		})
 	}
 
-	if locations == nil {
-		locations = []models.VehicleLocation{}
+	page := models.LocationPage{Locations: locations}
+	if next != nil {
+		page.NextCursor = next.Encode()
+	}
+
+	return c.JSON(page)
+}
+
+// streamHistory streams the location history of the vehicles straight
+// from the database to the client, so the range is never held in memory.
+// Errors after the response has started can only be logged; the output is
+// then left unterminated so clients notice the truncation.
+func (h *VehicleHandler) streamHistory(c *fiber.Ctx, format string, vehicleIDs []string, start, end int64) error {
+	c.Set(fiber.HeaderContentType, historyContentType(format))
+	if format != formatJSON && format != formatNDJSON {
+		// Let browsers save GIS formats as a file
+		c.Attachment(historyFilename(vehicleIDs, format))
+	}
+
+	enc := newHistoryEncoder(format, len(vehicleIDs) > 1)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := enc.begin(w); err != nil {
+			return
+		}
+
+		count := 0
+		for _, vehicleID := range vehicleIDs {
+			each := func(fn func(loc *models.VehicleLocation) error) error {
+				return h.repo.StreamLocationHistory(vehicleID, start, end, func(loc *models.VehicleLocation) error {
+					if err := fn(loc); err != nil {
+						return err
+					}
+
+					count++
+					if count%historyFlushEvery == 0 {
+						return w.Flush()
+					}
+					return nil
+				})
+			}
+
+			if err := enc.vehicle(w, vehicleID, each); err != nil {
+				log.Printf("Failed to stream location history of %s: %v", vehicleID, err)
+				return
+			}
+		}
+
+		if err := enc.end(w); err == nil {
+			w.Flush()
+		}
+	})
+
+	return nil
+}
+
+// historyFormat picks the history format from the format query parameter
+// or, without it, the Accept header. It returns "" when none is supported.
+func historyFormat(c *fiber.Ctx) string {
+	if format := c.Query("format"); format != "" {
+		if historyContentType(format) == "" {
+			return ""
+		}
+		return format
+	}
+
+	offers := make([]string, len(historyContentTypes))
+	for i, t := range historyContentTypes {
+		offers[i] = t.contentType
+	}
+
+	accepted := c.Accepts(offers...)
+	for _, t := range historyContentTypes {
+		if t.contentType == accepted {
+			return t.format
+		}
 	}
+	return ""
+}
+
+// historyContentType returns the content type of a history format, or ""
+// for an unknown format
+func historyContentType(format string) string {
+	for _, t := range historyContentTypes {
+		if t.format == format {
+			return t.contentType
+		}
+	}
+	return ""
+}
+
+// selectFields returns only the requested fields of a location. Optional
+// telemetry that wasn't reported is left out, like in the full response.
+func selectFields(loc *models.VehicleLocation, fields []string) fiber.Map {
+	result := fiber.Map{"vehicle_id": loc.VehicleID}
+	for _, field := range fields {
+		if value := locationFields[field](loc); value != nil {
+			result[field] = value
+		}
+	}
+	return result
+}
+
+// optional dereferences an optional field, nil when it is not set
+func optional[T any](v *T) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// splitList splits a comma-separated query parameter, ignoring empty items.
+// The items are copied, since Fiber reuses the request buffer once the
+// handler returns and some filters outlive the request.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.Clone(item))
+		}
+	}
+	return items
+}
+
+// parseTimeRange parses the required start and end query parameters
+func parseTimeRange(c *fiber.Ctx) (int64, int64, error) {
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+
+	if startStr == "" || endStr == "" {
+		return 0, 0, fmt.Errorf("start and end query parameters are required")
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start timestamp")
+	}
+
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end timestamp")
+	}
+
+	if start > end {
+		return 0, 0, fmt.Errorf("start timestamp must be less than or equal to end timestamp")
+	}
+
+	return start, end, nil
+}
+
+// parseBBox parses a minLon,minLat,maxLon,maxLat bounding box
+func parseBBox(value string) (*geofence.Bounds, error) {
+	coords := splitList(value)
+	if len(coords) != 4 {
+		return nil, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+
+	var values [4]float64
+	for i, coord := range coords {
+		v, err := strconv.ParseFloat(coord, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox coordinate: %s", coord)
+		}
+		values[i] = v
+	}
+
+	return newBBox(values)
+}
+
+// newBBox validates the corners of a minLon,minLat,maxLon,maxLat bounding
+// box
+func newBBox(values [4]float64) (*geofence.Bounds, error) {
+	bounds := geofence.Bounds{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if !validCoordinate(bounds.MinLat, bounds.MinLon) || !validCoordinate(bounds.MaxLat, bounds.MaxLon) ||
+		bounds.MinLat > bounds.MaxLat || bounds.MinLon > bounds.MaxLon {
+		return nil, fmt.Errorf("bbox is out of range or its minimum exceeds its maximum")
+	}
+	return &bounds, nil
+}
+
+// parseMaxAge parses the optional max_age query parameter in seconds
+func parseMaxAge(c *fiber.Ctx) (int64, error) {
+	maxAgeStr := c.Query("max_age")
+	if maxAgeStr == "" {
+		return 0, nil
+	}
+
+	maxAge, err := strconv.ParseInt(maxAgeStr, 10, 64)
+	if err != nil || maxAge <= 0 {
+		return 0, fmt.Errorf("max_age must be a positive number of seconds")
+	}
+	return maxAge, nil
+}
+
+// freshOnly drops vehicles whose location is older than maxAge seconds.
+// A maxAge of 0 keeps every vehicle. The result is never nil.
+func freshOnly(vehicles []models.NearbyVehicle, maxAge int64) []models.NearbyVehicle {
+	result := make([]models.NearbyVehicle, 0, len(vehicles))
+	cutoff := time.Now().Unix() - maxAge
+	for _, v := range vehicles {
+		if maxAge == 0 || v.Timestamp >= cutoff {
+			result = append(result, v)
+		}
+	}
+	return result
+}
 
-	return c.JSON(locations)
+// validCoordinate reports whether a latitude and longitude are in range
+func validCoordinate(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
 }
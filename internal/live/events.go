@@ -0,0 +1,204 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is one alert relayed to event stream clients. Data is the JSON
+// encoding of the event as published to RabbitMQ.
+type Event struct {
+	ID        int64
+	Type      string
+	VehicleID string
+	Data      []byte
+}
+
+// EventFilter selects the events a client receives. Empty fields match
+// everything; an event must match every non-empty field.
+type EventFilter struct {
+	Types      map[string]bool
+	VehicleIDs map[string]bool
+}
+
+// matches reports whether an event passes the filter
+func (f *EventFilter) matches(event *Event) bool {
+	if len(f.Types) > 0 && !f.Types[event.Type] {
+		return false
+	}
+	if len(f.VehicleIDs) > 0 && !f.VehicleIDs[event.VehicleID] {
+		return false
+	}
+	return true
+}
+
+// EventHub fans alerts out to event stream clients and keeps the most
+// recent ones so a reconnecting client can resume after the last event it
+// saw. Like LocationHub, publishing never blocks and drops slow clients.
+//
+// Event IDs increase by one per event and start from the time the hub was
+// created, so IDs issued after a restart are still higher than the ones
+// before it.
+type EventHub struct {
+	bufferSize int
+
+	mu      sync.Mutex
+	nextID  int64
+	history []Event // ring buffer of recent events
+	head    int     // index of the oldest event in history
+	count   int
+	clients map[*EventClient]struct{}
+	closed  bool
+}
+
+// NewEventHub creates an event hub remembering up to historySize events
+func NewEventHub(historySize, bufferSize int) *EventHub {
+	return &EventHub{
+		bufferSize: bufferSize,
+		nextID:     time.Now().UnixNano(),
+		history:    make([]Event, historySize),
+		clients:    make(map[*EventClient]struct{}),
+	}
+}
+
+// Publish assigns the next ID to an event and delivers it to every
+// matching client
+func (h *EventHub) Publish(eventType, vehicleID string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	event := Event{ID: h.nextID, Type: eventType, VehicleID: vehicleID, Data: data}
+	h.nextID++
+	h.remember(event)
+
+	for client := range h.clients {
+		if !client.filter.matches(&event) {
+			continue
+		}
+
+		select {
+		case client.send <- event:
+		default:
+			delete(h.clients, client)
+			client.stop(ErrSlowConsumer)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a new client receiving the events that match the
+// filter. When lastEventID is non-zero, the remembered events after it
+// that match the filter are returned so the client can send them first;
+// if it is older than every remembered event, all of them are returned.
+func (h *EventHub) Subscribe(filter EventFilter, lastEventID int64) (*EventClient, []Event) {
+	client := &EventClient{
+		hub:    h,
+		filter: filter,
+		send:   make(chan Event, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		client.stop(ErrHubClosed)
+		return client, nil
+	}
+	h.clients[client] = struct{}{}
+
+	var backlog []Event
+	if lastEventID != 0 {
+		for i := 0; i < h.count; i++ {
+			event := &h.history[(h.head+i)%len(h.history)]
+			if event.ID > lastEventID && filter.matches(event) {
+				backlog = append(backlog, *event)
+			}
+		}
+	}
+
+	return client, backlog
+}
+
+// Close disconnects every client and rejects new ones
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for client := range h.clients {
+		delete(h.clients, client)
+		client.stop(ErrHubClosed)
+	}
+}
+
+// Len returns the number of connected clients
+func (h *EventHub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// remember adds an event to the history, overwriting the oldest one when
+// it is full. The caller must hold the lock.
+func (h *EventHub) remember(event Event) {
+	if len(h.history) == 0 {
+		return
+	}
+
+	if h.count < len(h.history) {
+		h.history[(h.head+h.count)%len(h.history)] = event
+		h.count++
+		return
+	}
+
+	h.history[h.head] = event
+	h.head = (h.head + 1) % len(h.history)
+}
+
+// EventClient is one event stream subscriber
+type EventClient struct {
+	hub    *EventHub
+	filter EventFilter
+
+	send chan Event
+	done chan struct{}
+	err  error
+}
+
+// Events returns the channel of events for the client
+func (c *EventClient) Events() <-chan Event {
+	return c.send
+}
+
+// Done is closed when the hub disconnects the client, either for falling
+// behind or because the hub is closing
+func (c *EventClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client was disconnected once Done is closed
+func (c *EventClient) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Close unregisters the client from the hub
+func (c *EventClient) Close() {
+	c.hub.mu.Lock()
+	delete(c.hub.clients, c)
+	c.hub.mu.Unlock()
+}
+
+// stop records why the client is disconnected and closes Done
+func (c *EventClient) stop(reason error) {
+	c.err = reason
+	close(c.done)
+}
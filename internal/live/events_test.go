@@ -0,0 +1,115 @@
+package live
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// publishEvents publishes geofence_entry events for the vehicles and
+// returns their IDs
+func publishEvents(t *testing.T, h *EventHub, vehicleIDs ...string) []int64 {
+	t.Helper()
+
+	recorder, _ := h.Subscribe(EventFilter{}, 0)
+	defer recorder.Close()
+
+	ids := make([]int64, 0, len(vehicleIDs))
+	for _, vehicleID := range vehicleIDs {
+		if err := h.Publish("geofence_entry", vehicleID, map[string]string{"vehicle_id": vehicleID}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, (<-recorder.Events()).ID)
+	}
+	return ids
+}
+
+func eventIDs(events []Event) []int64 {
+	var ids []int64
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestEventHubReplay(t *testing.T) {
+	h := NewEventHub(3, 8)
+	defer h.Close()
+
+	ids := publishEvents(t, h, "B1", "B2", "B1", "B2", "B1")
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("event IDs %v don't increase by one", ids)
+		}
+	}
+
+	// Only the last three events are remembered
+	tests := []struct {
+		name        string
+		lastEventID int64
+		filter      EventFilter
+		want        []int64
+	}{
+		{"no Last-Event-ID", 0, EventFilter{}, nil},
+		{"resume mid history", ids[3], EventFilter{}, ids[4:]},
+		{"resume at the oldest remembered", ids[2], EventFilter{}, ids[3:]},
+		{"resume before the history", ids[0], EventFilter{}, ids[2:]},
+		{"up to date", ids[4], EventFilter{}, nil},
+		{"filtered by vehicle", ids[0], EventFilter{VehicleIDs: map[string]bool{"B2": true}}, ids[3:4]},
+		{"filtered by type", ids[0], EventFilter{Types: map[string]bool{"overspeed": true}}, nil},
+	}
+
+	for _, tt := range tests {
+		client, backlog := h.Subscribe(tt.filter, tt.lastEventID)
+		client.Close()
+
+		if got := eventIDs(backlog); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got backlog %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventHubDelivery(t *testing.T) {
+	h := NewEventHub(10, 8)
+	defer h.Close()
+
+	entries, _ := h.Subscribe(EventFilter{Types: map[string]bool{"geofence_entry": true}}, 0)
+	b2, _ := h.Subscribe(EventFilter{VehicleIDs: map[string]bool{"B2": true}}, 0)
+
+	h.Publish("geofence_entry", "B1", struct{}{})
+	h.Publish("overspeed", "B2", struct{}{})
+
+	if got := len(entries.Events()); got != 1 {
+		t.Errorf("type filter got %d events, want 1", got)
+	}
+	if e := <-b2.Events(); e.Type != "overspeed" || string(e.Data) != "{}" {
+		t.Errorf("vehicle filter got %+v", e)
+	}
+
+	if err := h.Publish("overspeed", "B2", make(chan int)); err == nil {
+		t.Error("publishing an unencodable payload succeeded")
+	}
+}
+
+func TestEventHubDropsSlowClient(t *testing.T) {
+	h := NewEventHub(10, 1)
+	defer h.Close()
+
+	slow, _ := h.Subscribe(EventFilter{}, 0)
+	h.Publish("geofence_entry", "B1", nil)
+	h.Publish("geofence_entry", "B1", nil)
+
+	if !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("got %v, want ErrSlowConsumer", slow.Err())
+	}
+	if n := h.Len(); n != 0 {
+		t.Errorf("got %d clients, want the slow one removed", n)
+	}
+
+	// The dropped client can still resume from what it received
+	first := <-slow.Events()
+	_, backlog := h.Subscribe(EventFilter{}, first.ID)
+	if len(backlog) != 1 || backlog[0].ID != first.ID+1 {
+		t.Errorf("got backlog %v after reconnecting, want the missed event", eventIDs(backlog))
+	}
+}
@@ -0,0 +1,196 @@
+package live
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+var (
+	// ErrSlowConsumer is reported when a client is dropped because its
+	// buffer filled up
+	ErrSlowConsumer = errors.New("client too slow")
+
+	// ErrHubClosed is reported when a client is disconnected because the
+	// hub is shutting down
+	ErrHubClosed = errors.New("server shutting down")
+)
+
+// RouteLookup returns the route a vehicle is assigned to, or "" when it
+// has none
+type RouteLookup func(vehicleID string) string
+
+// Filter selects the locations a client receives. Empty fields match
+// everything; a location must match every non-empty field.
+type Filter struct {
+	VehicleIDs map[string]bool
+	RouteIDs   map[string]bool
+	Bounds     *geofence.Bounds
+}
+
+// matches reports whether a location passes the filter
+func (f *Filter) matches(loc *models.VehicleLocation, routeOf RouteLookup) bool {
+	if len(f.VehicleIDs) > 0 && !f.VehicleIDs[loc.VehicleID] {
+		return false
+	}
+	if len(f.RouteIDs) > 0 && !f.RouteIDs[routeOf(loc.VehicleID)] {
+		return false
+	}
+	if f.Bounds != nil && !f.Bounds.Contains(loc.Latitude, loc.Longitude) {
+		return false
+	}
+	return true
+}
+
+// LocationHub fans accepted vehicle locations out to live feed clients.
+// Publishing never blocks: a client whose buffer is full is dropped so a
+// slow consumer can't hold up ingest.
+type LocationHub struct {
+	routeOf    RouteLookup
+	bufferSize int
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	closed  bool
+}
+
+// NewLocationHub creates a new location hub
+func NewLocationHub(routeOf RouteLookup, bufferSize int) *LocationHub {
+	return &LocationHub{
+		routeOf:    routeOf,
+		bufferSize: bufferSize,
+		clients:    make(map[*Client]struct{}),
+	}
+}
+
+// Subscribe registers a new client receiving the locations that match the
+// filter
+func (h *LocationHub) Subscribe(filter Filter) *Client {
+	client := &Client{
+		hub:    h,
+		filter: filter,
+		send:   make(chan models.VehicleLocation, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		client.stop(ErrHubClosed)
+	} else {
+		h.clients[client] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return client
+}
+
+// Publish delivers a location to every matching client
+func (h *LocationHub) Publish(loc *models.VehicleLocation) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for client := range h.clients {
+		if !client.matches(loc, h.routeOf) {
+			continue
+		}
+
+		select {
+		case client.send <- *loc:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range slow {
+		h.remove(client, ErrSlowConsumer)
+	}
+}
+
+// Close disconnects every client and rejects new ones
+func (h *LocationHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for client := range h.clients {
+		delete(h.clients, client)
+		client.stop(ErrHubClosed)
+	}
+}
+
+// Len returns the number of connected clients
+func (h *LocationHub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// remove unregisters a client. A non-nil reason stops the client with
+// that reason.
+func (h *LocationHub) remove(client *Client, reason error) {
+	h.mu.Lock()
+	_, ok := h.clients[client]
+	delete(h.clients, client)
+	h.mu.Unlock()
+
+	if ok && reason != nil {
+		client.stop(reason)
+	}
+}
+
+// Client is one live feed subscriber
+type Client struct {
+	hub *LocationHub
+
+	mu     sync.RWMutex
+	filter Filter
+
+	send chan models.VehicleLocation
+	done chan struct{}
+	err  error
+}
+
+// Locations returns the channel of locations for the client
+func (c *Client) Locations() <-chan models.VehicleLocation {
+	return c.send
+}
+
+// Done is closed when the hub disconnects the client, either for falling
+// behind or because the hub is closing
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client was disconnected once Done is closed
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// SetFilter replaces the client's filter
+func (c *Client) SetFilter(filter Filter) {
+	c.mu.Lock()
+	c.filter = filter
+	c.mu.Unlock()
+}
+
+// Close unregisters the client from the hub
+func (c *Client) Close() {
+	c.hub.remove(c, nil)
+}
+
+// stop records why the client is disconnected and closes Done
+func (c *Client) stop(reason error) {
+	c.err = reason
+	close(c.done)
+}
+
+// matches reports whether a location passes the client's filter
+func (c *Client) matches(loc *models.VehicleLocation, routeOf RouteLookup) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.matches(loc, routeOf)
+}
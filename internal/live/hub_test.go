@@ -0,0 +1,147 @@
+package live_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/live"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+var routes = map[string]string{"B1": "koridor-1", "B2": "koridor-1", "B3": "koridor-9"}
+
+func routeOf(vehicleID string) string {
+	return routes[vehicleID]
+}
+
+func publish(h *live.LocationHub, vehicleID string, lat, lon float64) {
+	h.Publish(&models.VehicleLocation{VehicleID: vehicleID, Latitude: lat, Longitude: lon, Timestamp: 1715000000})
+}
+
+// received drains the locations already queued for a client
+func received(c *live.Client) []string {
+	var ids []string
+	for {
+		select {
+		case loc := <-c.Locations():
+			ids = append(ids, loc.VehicleID)
+		default:
+			return ids
+		}
+	}
+}
+
+func set(ids ...string) map[string]bool {
+	m := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+func TestLocationHubFilters(t *testing.T) {
+	h := live.NewLocationHub(routeOf, 16)
+	defer h.Close()
+
+	all := h.Subscribe(live.Filter{})
+	vehicles := h.Subscribe(live.Filter{VehicleIDs: set("B1", "B3")})
+	route := h.Subscribe(live.Filter{RouteIDs: set("koridor-1")})
+	area := h.Subscribe(live.Filter{Bounds: &geofence.Bounds{MinLat: -6.3, MinLon: 106.7, MaxLat: -6.1, MaxLon: 106.9}})
+	both := h.Subscribe(live.Filter{
+		RouteIDs: set("koridor-1"),
+		Bounds:   &geofence.Bounds{MinLat: -6.3, MinLon: 106.7, MaxLat: -6.1, MaxLon: 106.9},
+	})
+
+	publish(h, "B1", -6.2, 106.8) // koridor-1, inside
+	publish(h, "B2", -6.5, 106.8) // koridor-1, outside
+	publish(h, "B3", -6.2, 106.8) // koridor-9, inside
+	publish(h, "B4", -6.2, 106.8) // no route, inside
+
+	for name, tt := range map[string]struct {
+		client *live.Client
+		want   []string
+	}{
+		"no filter": {all, []string{"B1", "B2", "B3", "B4"}},
+		"vehicles":  {vehicles, []string{"B1", "B3"}},
+		"route":     {route, []string{"B1", "B2"}},
+		"bbox":      {area, []string{"B1", "B3", "B4"}},
+		"all match": {both, []string{"B1"}},
+	} {
+		if got := received(tt.client); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestLocationHubSetFilter(t *testing.T) {
+	h := live.NewLocationHub(routeOf, 16)
+	defer h.Close()
+
+	c := h.Subscribe(live.Filter{VehicleIDs: set("B1")})
+	publish(h, "B2", -6.2, 106.8)
+	c.SetFilter(live.Filter{VehicleIDs: set("B2")})
+	publish(h, "B2", -6.2, 106.8)
+	publish(h, "B1", -6.2, 106.8)
+
+	if got := received(c); !reflect.DeepEqual(got, []string{"B2"}) {
+		t.Errorf("got %v, want only the location after the filter change", got)
+	}
+}
+
+func TestLocationHubDropsSlowClient(t *testing.T) {
+	h := live.NewLocationHub(routeOf, 2)
+	defer h.Close()
+
+	slow := h.Subscribe(live.Filter{})
+	fast := h.Subscribe(live.Filter{})
+
+	for i := 0; i < 3; i++ {
+		publish(h, "B1", -6.2, 106.8)
+		received(fast)
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("slow client wasn't dropped when its buffer overflowed")
+	}
+	if !errors.Is(slow.Err(), live.ErrSlowConsumer) {
+		t.Errorf("got %v, want ErrSlowConsumer", slow.Err())
+	}
+	if n := h.Len(); n != 1 {
+		t.Errorf("got %d clients, want the fast one left", n)
+	}
+
+	// Publishing carries on for everyone else
+	publish(h, "B1", -6.2, 106.8)
+	if got := received(fast); len(got) != 1 {
+		t.Errorf("fast client got %v after the drop", got)
+	}
+}
+
+func TestLocationHubClose(t *testing.T) {
+	h := live.NewLocationHub(routeOf, 4)
+
+	c := h.Subscribe(live.Filter{})
+	gone := h.Subscribe(live.Filter{})
+	gone.Close()
+	if n := h.Len(); n != 1 {
+		t.Fatalf("got %d clients after one left, want 1", n)
+	}
+
+	h.Close()
+	if !errors.Is(c.Err(), live.ErrHubClosed) {
+		t.Errorf("got %v, want ErrHubClosed", c.Err())
+	}
+
+	late := h.Subscribe(live.Filter{})
+	if !errors.Is(late.Err(), live.ErrHubClosed) {
+		t.Errorf("subscribing after Close got %v, want ErrHubClosed", late.Err())
+	}
+	publish(h, "B1", -6.2, 106.8) // must not panic or deliver
+	if got := received(late); len(got) != 0 {
+		t.Errorf("client of a closed hub got %v", got)
+	}
+}
@@ -0,0 +1,197 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/database"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/repository"
+)
+
+const (
+	// downsampleWindow is the slice of history downsampled at a time
+	downsampleWindow = time.Hour
+
+	// downsampleStateKey stores the end of the last downsampled window
+	downsampleStateKey = "location_downsample_until"
+)
+
+// Job downsamples old location history and purges history past the
+// retention window
+type Job struct {
+	repo       *repository.MaintenanceRepository
+	partitions *database.PartitionManager
+
+	method     string
+	resolution int64   // in seconds, for MethodInterval
+	tolerance  float64 // in meters, for MethodDouglasPeucker
+	after      time.Duration
+	retention  time.Duration // 0 keeps everything
+}
+
+// NewJob creates a new maintenance job
+func NewJob(repo *repository.MaintenanceRepository, partitions *database.PartitionManager, cfg *config.Config) (*Job, error) {
+	switch cfg.DownsampleMethod {
+	case MethodInterval:
+		if cfg.DownsampleResolution < time.Second {
+			return nil, fmt.Errorf("downsample resolution must be at least one second")
+		}
+	case MethodDouglasPeucker:
+		if cfg.DownsampleTolerance <= 0 {
+			return nil, fmt.Errorf("downsample tolerance must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("invalid downsample method %q: must be %s or %s",
+			cfg.DownsampleMethod, MethodInterval, MethodDouglasPeucker)
+	}
+
+	return &Job{
+		repo:       repo,
+		partitions: partitions,
+		method:     cfg.DownsampleMethod,
+		resolution: int64(cfg.DownsampleResolution / time.Second),
+		tolerance:  cfg.DownsampleTolerance,
+		after:      cfg.DownsampleAfter,
+		retention:  cfg.LocationRetention,
+	}, nil
+}
+
+// RunOnce downsamples every window older than the downsample age that has
+// not been processed yet, then removes history past the retention window
+func (j *Job) RunOnce(ctx context.Context, now time.Time) error {
+	if err := j.downsample(ctx, now); err != nil {
+		return err
+	}
+
+	if err := j.partitions.EnsurePartitions(now); err != nil {
+		return err
+	}
+	if _, err := j.partitions.EnforceRetention(now); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Run executes the job immediately and then on every interval until the
+// context is cancelled
+func (j *Job) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.RunOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("Maintenance run failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// downsample thins out the history window by window, recording progress
+// after each window so an interrupted run resumes where it stopped
+func (j *Job) downsample(ctx context.Context, now time.Time) error {
+	window := int64(downsampleWindow / time.Second)
+	end := now.Add(-j.after).Unix() / window * window
+
+	var from int64
+	state, err := j.repo.GetState(downsampleStateKey)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		from = *state
+	}
+	if j.retention > 0 {
+		// History past the retention window is purged, not downsampled
+		if cutoff := now.Add(-j.retention).Unix() / window * window; from < cutoff {
+			from = cutoff
+		}
+	}
+
+	var windows int
+	var kept, removed int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Jump straight to the next window holding rows, so a stray old
+		// fix doesn't cost a query for every empty hour since
+		next, err := j.repo.GetOldestTimestamp(from)
+		if err != nil {
+			return err
+		}
+		if next == nil || *next >= end {
+			break
+		}
+		ws := *next / window * window
+
+		k, r, err := j.downsampleWindow(ws, ws+window)
+		if err != nil {
+			return err
+		}
+		if err := j.repo.SetState(downsampleStateKey, ws+window); err != nil {
+			return err
+		}
+		from = ws + window
+
+		windows++
+		kept += k
+		removed += r
+	}
+
+	if windows > 0 {
+		log.Printf("Downsampled %d hours of location history (%s): kept %d, removed %d rows",
+			windows, j.method, kept, removed)
+	}
+	return nil
+}
+
+// downsampleWindow downsamples every vehicle track in [start, end) and
+// returns the number of rows kept and removed
+func (j *Job) downsampleWindow(start, end int64) (int64, int64, error) {
+	vehicleIDs, err := j.repo.ListVehicleIDs(start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var kept, removed int64
+	for _, vehicleID := range vehicleIDs {
+		track, err := j.repo.GetTrack(vehicleID, start, end)
+		if err != nil {
+			return kept, removed, err
+		}
+
+		var keep []bool
+		if j.method == MethodDouglasPeucker {
+			keep = keepDouglasPeucker(track, j.tolerance)
+		} else {
+			keep = keepInterval(track, j.resolution)
+		}
+
+		var drop []int64
+		for i := range track {
+			if !keep[i] {
+				drop = append(drop, track[i].ID)
+			}
+		}
+
+		deleted, err := j.repo.DeleteLocations(vehicleID, start, end, drop)
+		if err != nil {
+			return kept, removed, err
+		}
+
+		kept += int64(len(track) - len(drop))
+		removed += deleted
+	}
+
+	return kept, removed, nil
+}
@@ -0,0 +1,104 @@
+package maintenance
+
+import (
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Downsampling methods
+const (
+	MethodInterval       = "interval"
+	MethodDouglasPeucker = "douglas-peucker"
+)
+
+// keepInterval marks the first point of every resolution-second bucket of
+// a track ordered by timestamp. The last point and every point where the
+// ignition or door state changes are kept as well so trips and stops can
+// still be reconstructed from the downsampled track.
+func keepInterval(track []models.StoredLocation, resolution int64) []bool {
+	keep := make([]bool, len(track))
+	if len(track) == 0 {
+		return keep
+	}
+
+	lastBucket := int64(-1)
+	for i := range track {
+		bucket := track[i].Timestamp / resolution
+		if bucket != lastBucket || (i > 0 && stateChanged(&track[i-1], &track[i])) {
+			keep[i] = true
+			lastBucket = bucket
+		}
+	}
+	keep[len(track)-1] = true
+
+	return keep
+}
+
+// keepDouglasPeucker marks the points of a track that survive
+// Douglas-Peucker simplification with the given tolerance in meters.
+// Points where the ignition or door state changes are always kept.
+func keepDouglasPeucker(track []models.StoredLocation, tolerance float64) []bool {
+	keep := make([]bool, len(track))
+	if len(track) == 0 {
+		return keep
+	}
+
+	keep[0] = true
+	keep[len(track)-1] = true
+	for i := 1; i < len(track); i++ {
+		if stateChanged(&track[i-1], &track[i]) {
+			keep[i] = true
+		}
+	}
+
+	// Simplify every stretch between two already kept points. An explicit
+	// stack avoids deep recursion on long, straight tracks.
+	type span struct{ first, last int }
+	var stack []span
+	first := 0
+	for i := 1; i < len(track); i++ {
+		if keep[i] {
+			stack = append(stack, span{first, i})
+			first = i
+		}
+	}
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		a := location(&track[s.first])
+		b := location(&track[s.last])
+
+		farthest, maxDistance := -1, tolerance
+		for i := s.first + 1; i < s.last; i++ {
+			d := geofence.DistanceToSegment(track[i].Latitude, track[i].Longitude, a, b)
+			if d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, span{s.first, farthest}, span{farthest, s.last})
+		}
+	}
+
+	return keep
+}
+
+// stateChanged reports whether the ignition or door state differs between
+// two consecutive points
+func stateChanged(prev, cur *models.StoredLocation) bool {
+	return boolChanged(prev.Ignition, cur.Ignition) || boolChanged(prev.DoorOpen, cur.DoorOpen)
+}
+
+// boolChanged reports whether two optional flags are both set and differ
+func boolChanged(a, b *bool) bool {
+	return a != nil && b != nil && *a != *b
+}
+
+// location converts a stored location into a plain coordinate
+func location(loc *models.StoredLocation) models.Location {
+	return models.Location{Latitude: loc.Latitude, Longitude: loc.Longitude}
+}
@@ -1,19 +1,108 @@
 package models
 
-// VehicleLocation represents the location data of a vehicle
+import "encoding/json"
+
+// VehicleLocation represents the location data of a vehicle. Telemetry
+// fields other than the position are optional and nil when not reported.
 type VehicleLocation struct {
 	VehicleID string  `json:"vehicle_id"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	Timestamp int64   `json:"timestamp"`
+
+	Speed    *float64 `json:"speed,omitempty"`    // in km/h, derived from the previous fix when not reported
+	Heading  *float64 `json:"heading,omitempty"`  // in degrees clockwise from north
+	HDOP     *float64 `json:"hdop,omitempty"`     // horizontal dilution of precision
+	Accuracy *float64 `json:"accuracy,omitempty"` // estimated horizontal accuracy in meters
+	Odometer *float64 `json:"odometer,omitempty"` // in kilometers
+	Ignition *bool    `json:"ignition,omitempty"`
+	DoorOpen *bool    `json:"door_open,omitempty"`
 }
 
-// GeofenceEvent represents an event when vehicle enters a geofence
+// LocationPage is one page of a vehicle's location history
+type LocationPage struct {
+	Locations  []VehicleLocation `json:"locations"`
+	NextCursor string            `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// NearbyVehicle is the latest location of a vehicle returned by a spatial
+// query, with its distance to the query point
+type NearbyVehicle struct {
+	VehicleLocation
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// StoredLocation is a vehicle location together with its database row ID
+type StoredLocation struct {
+	ID int64 `json:"id"`
+	VehicleLocation
+}
+
+// Geofence event types
+const (
+	EventGeofenceEntry = "geofence_entry"
+	EventGeofenceExit  = "geofence_exit"
+	EventGeofenceDwell = "geofence_dwell"
+)
+
+// GeofenceEvent represents an event when vehicle enters, exits or dwells
+// inside a geofence
 type GeofenceEvent struct {
-	VehicleID string   `json:"vehicle_id"`
-	Event     string   `json:"event"`
-	Location  Location `json:"location"`
-	Timestamp int64    `json:"timestamp"`
+	VehicleID    string   `json:"vehicle_id"`
+	Event        string   `json:"event"`
+	GeofenceID   string   `json:"geofence_id,omitempty"`
+	GeofenceName string   `json:"geofence_name,omitempty"`
+	DwellSeconds int64    `json:"dwell_seconds,omitempty"`
+	Location     Location `json:"location"`
+	Timestamp    int64    `json:"timestamp"`
+}
+
+// Route event types
+const (
+	EventOffRoute    = "off_route"
+	EventBackOnRoute = "back_on_route"
+)
+
+// RouteEvent represents an event when a vehicle leaves or returns to its
+// assigned route
+type RouteEvent struct {
+	VehicleID       string   `json:"vehicle_id"`
+	Event           string   `json:"event"`
+	RouteID         string   `json:"route_id"`
+	RouteName       string   `json:"route_name,omitempty"`
+	DistanceMeters  float64  `json:"distance_meters"`  // distance from the route at the time of the event
+	DurationSeconds int64    `json:"duration_seconds"` // time spent away from the route so far
+	Location        Location `json:"location"`
+	Timestamp       int64    `json:"timestamp"`
+}
+
+// Speed event types
+const (
+	EventOverspeed    = "overspeed"
+	EventOverspeedEnd = "overspeed_end"
+)
+
+// OverspeedEvent represents a period in which a vehicle exceeded the
+// speed limit, raised once it lasted the minimum duration and again when
+// it ended. Location is where the peak speed so far was recorded.
+type OverspeedEvent struct {
+	VehicleID       string   `json:"vehicle_id"`
+	Event           string   `json:"event"`
+	SpeedLimit      float64  `json:"speed_limit"` // in km/h
+	PeakSpeed       float64  `json:"peak_speed"`  // in km/h
+	DurationSeconds int64    `json:"duration_seconds"`
+	StartedAt       int64    `json:"started_at"`
+	GeofenceID      string   `json:"geofence_id,omitempty"` // speed zone, empty for the global limit
+	GeofenceName    string   `json:"geofence_name,omitempty"`
+	Location        Location `json:"location"`
+	Timestamp       int64    `json:"timestamp"`
+}
+
+// RouteAssignment links a vehicle to the corridor geofence it should follow
+type RouteAssignment struct {
+	VehicleID  string `json:"vehicle_id"`
+	RouteID    string `json:"route_id"`
+	AssignedAt int64  `json:"assigned_at"`
 }
 
 // Location represents a geographic location
@@ -22,6 +111,99 @@ type Location struct {
 	Longitude float64 `json:"longitude"`
 }
 
+// Geofence represents a geofence stored in the database. Geometry is a
+// GeoJSON Point, Polygon, MultiPolygon, LineString or MultiLineString;
+// Point geometries require Radius and line geometries require Buffer.
+type Geofence struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Geometry json.RawMessage `json:"geometry"`
+	Radius   float64         `json:"radius,omitempty"` // in meters
+	Buffer   float64         `json:"buffer,omitempty"` // in meters
+
+	SpeedLimit float64 `json:"speed_limit,omitempty"` // in km/h, 0 when the geofence is not a speed zone
+	ExpiresAt  *int64  `json:"expires_at,omitempty"`  // unix timestamp, nil for permanent fences
+
+	// Hysteresis settings, nil uses the server defaults
+	ExitBuffer *float64 `json:"exit_buffer,omitempty"` // in meters
+	MinPings   *int     `json:"min_pings,omitempty"`
+	MinSeconds *int64   `json:"min_seconds,omitempty"`
+
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// Trip end reasons
+const (
+	TripEndIgnitionOff = "ignition_off"
+	TripEndStopped     = "stopped"  // stationary for the stop duration
+	TripEndTerminal    = "terminal" // stopped inside a terminal geofence
+	TripEndGap         = "gap"      // no locations for the stop duration
+)
+
+// Place is the geofence a trip started or ended in
+type Place struct {
+	GeofenceID string `json:"geofence_id"`
+	Name       string `json:"name"`
+}
+
+// Trip is a continuous journey of one vehicle between two stops. EndedAt,
+// EndLocation and EndReason are nil while the trip is in progress, and
+// distance and duration then reflect the last time it was saved.
+type Trip struct {
+	ID              string    `json:"id"`
+	VehicleID       string    `json:"vehicle_id"`
+	StartedAt       int64     `json:"started_at"`
+	EndedAt         *int64    `json:"ended_at"`
+	StartLocation   Location  `json:"start_location"`
+	EndLocation     *Location `json:"end_location"`
+	StartPlace      *Place    `json:"start_place"`
+	EndPlace        *Place    `json:"end_place"`
+	DistanceMeters  float64   `json:"distance_meters"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	EndReason       string    `json:"end_reason,omitempty"`
+}
+
+// VehicleSummary is the movement of one vehicle over a time range
+type VehicleSummary struct {
+	VehicleID      string  `json:"vehicle_id"`
+	Start          int64   `json:"start"`
+	End            int64   `json:"end"`
+	DistanceMeters float64 `json:"distance_meters"`
+	MovingSeconds  int64   `json:"moving_seconds"`
+	IdleSeconds    int64   `json:"idle_seconds"` // stationary with the ignition on or unknown
+	MaxSpeed       float64 `json:"max_speed"`    // in km/h
+	AvgSpeed       float64 `json:"avg_speed"`    // in km/h, over the moving time
+	Stops          int     `json:"stops"`
+	Points         int     `json:"points"`
+}
+
+// API key roles, from least to most privileged
+const (
+	RoleViewer     = "viewer"
+	RoleDispatcher = "dispatcher"
+	RoleAdmin      = "admin"
+)
+
+// APIKey represents a stored API key. The key itself is only returned
+// once, when it is created.
+type APIKey struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"` // first characters of the key, to tell keys apart
+	Role      string `json:"role"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt *int64 `json:"expires_at,omitempty"` // unix timestamp, nil for keys that don't expire
+	RevokedAt *int64 `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKey is returned when an API key is created, with the plaintext
+// key that can't be retrieved again
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
 // ErrorResponse represents an API error response
 type ErrorResponse struct {
 	Error string `json:"error"`
@@ -4,17 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	pahomqtt "github.com/eclipse/paho.mqtt.golang"
 
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/workerpool"
 )
 
-// Subscriber handles MQTT subscription for vehicle locations
+// Subscriber handles MQTT subscription for vehicle locations. Messages
+// are handled on a worker pool sharded by vehicle ID, so each vehicle's
+// locations are processed in order while different vehicles are
+// processed in parallel.
 type Subscriber struct {
 	client  pahomqtt.Client
 	handler func(*models.VehicleLocation)
+	pool    *workerpool.Pool
 	cfg     *config.Config
 }
 
@@ -36,6 +42,7 @@ func NewSubscriber(cfg *config.Config, handler func(*models.VehicleLocation)) (*
 	return &Subscriber{
 		client:  client,
 		handler: handler,
+		pool:    workerpool.New(cfg.IngestWorkers, cfg.IngestQueueSize),
 		cfg:     cfg,
 	}, nil
 }
@@ -62,12 +69,15 @@ func (s *Subscriber) Subscribe() error {
 		}
 
 		// Validate data
-		if err := validateLocation(&location); err != nil {
+		if err := validateLocation(&location, time.Now(), s.cfg); err != nil {
 			log.Printf("Invalid location data: %v", err)
 			return
 		}
 
-		s.handler(&location)
+		// Blocks while the vehicle's worker queue is full
+		if !s.pool.Submit(location.VehicleID, func() { s.handler(&location) }) {
+			log.Printf("Dropped location for vehicle %s: subscriber is shutting down", location.VehicleID)
+		}
 	}
 
 	token := s.client.Subscribe(topic, 1, messageHandler)
@@ -79,14 +89,23 @@ func (s *Subscriber) Subscribe() error {
 	return nil
 }
 
-// Disconnect closes the MQTT connection
+// QueueDepth returns the number of messages waiting to be handled
+func (s *Subscriber) QueueDepth() int {
+	return s.pool.QueueDepth()
+}
+
+// Disconnect closes the MQTT connection and waits for queued messages to
+// be handled
 func (s *Subscriber) Disconnect() {
 	s.client.Disconnect(250)
+	s.pool.Close()
 	log.Println("Disconnected from MQTT broker")
 }
 
-// validateLocation validates the location data
-func validateLocation(loc *models.VehicleLocation) error {
+// validateLocation validates the location data. The timestamp must not be
+// ahead of now by more than the allowed clock skew, which catches
+// timestamps sent in milliseconds, nor older than the downsample age.
+func validateLocation(loc *models.VehicleLocation, now time.Time, cfg *config.Config) error {
 	if loc.VehicleID == "" {
 		return fmt.Errorf("vehicle_id is required")
 	}
@@ -99,9 +118,32 @@ func validateLocation(loc *models.VehicleLocation) error {
 		return fmt.Errorf("invalid longitude: %f", loc.Longitude)
 	}
 
-	if loc.Timestamp <= 0 {
+	if loc.Timestamp <= 0 || loc.Timestamp > now.Add(cfg.LocationMaxFuture).Unix() {
 		return fmt.Errorf("invalid timestamp: %d", loc.Timestamp)
 	}
+	if loc.Timestamp < now.Add(-cfg.DownsampleAfter).Unix() {
+		return fmt.Errorf("timestamp too old: %d", loc.Timestamp)
+	}
+
+	if loc.Speed != nil && (*loc.Speed < 0 || *loc.Speed > 300) {
+		return fmt.Errorf("invalid speed: %f", *loc.Speed)
+	}
+
+	if loc.Heading != nil && (*loc.Heading < 0 || *loc.Heading > 360) {
+		return fmt.Errorf("invalid heading: %f", *loc.Heading)
+	}
+
+	if loc.HDOP != nil && *loc.HDOP < 0 {
+		return fmt.Errorf("invalid hdop: %f", *loc.HDOP)
+	}
+
+	if loc.Accuracy != nil && *loc.Accuracy < 0 {
+		return fmt.Errorf("invalid accuracy: %f", *loc.Accuracy)
+	}
+
+	if loc.Odometer != nil && *loc.Odometer < 0 {
+		return fmt.Errorf("invalid odometer: %f", *loc.Odometer)
+	}
 
 	return nil
 }
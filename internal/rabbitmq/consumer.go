@@ -11,7 +11,7 @@ import (
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 )
 
-// Consumer handles RabbitMQ consumption for geofence alerts
+// Consumer handles RabbitMQ consumption for geofence, route and speed alerts
 type Consumer struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
@@ -43,43 +43,9 @@ func (c *Consumer) Connect() error {
 		return fmt.Errorf("failed to open channel: %w", err)
 	}
 
-	// Declare exchange (ensure it exists)
-	err = c.channel.ExchangeDeclare(
-		ExchangeName, // name
-		"direct",     // type
-		true,         // durable
-		false,        // auto-deleted
-		false,        // internal
-		false,        // no-wait
-		nil,          // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to declare exchange: %w", err)
-	}
-
-	// Declare queue (ensure it exists)
-	_, err = c.channel.QueueDeclare(
-		QueueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,       // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to declare queue: %w", err)
-	}
-
-	// Bind queue to exchange
-	err = c.channel.QueueBind(
-		QueueName,    // queue name
-		RoutingKey,   // routing key
-		ExchangeName, // exchange
-		false,        // no-wait
-		nil,          // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to bind queue: %w", err)
+	// Declare exchange, queues and bindings (ensure they exist)
+	if err := declareTopology(c.channel); err != nil {
+		return err
 	}
 
 	log.Println("Consumer connected to RabbitMQ")
@@ -88,30 +54,65 @@ func (c *Consumer) Connect() error {
 
 // Consume starts consuming messages from the geofence_alerts queue
 func (c *Consumer) Consume(handler func(*models.GeofenceEvent)) error {
+	return c.consume(QueueName, func(body []byte) error {
+		var event models.GeofenceEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return err
+		}
+		handler(&event)
+		return nil
+	})
+}
+
+// ConsumeRouteEvents starts consuming messages from the route_alerts queue
+func (c *Consumer) ConsumeRouteEvents(handler func(*models.RouteEvent)) error {
+	return c.consume(RouteQueueName, func(body []byte) error {
+		var event models.RouteEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return err
+		}
+		handler(&event)
+		return nil
+	})
+}
+
+// ConsumeOverspeedEvents starts consuming messages from the speed_alerts queue
+func (c *Consumer) ConsumeOverspeedEvents(handler func(*models.OverspeedEvent)) error {
+	return c.consume(SpeedQueueName, func(body []byte) error {
+		var event models.OverspeedEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return err
+		}
+		handler(&event)
+		return nil
+	})
+}
+
+// consume delivers every message of a queue to handle, acknowledging it
+// on success and rejecting it when it cannot be decoded
+func (c *Consumer) consume(queue string, handle func(body []byte) error) error {
 	msgs, err := c.channel.Consume(
-		QueueName, // queue
-		"",        // consumer tag
-		false,     // auto-ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // arguments
+		queue, // queue
+		"",    // consumer tag
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // arguments
 	)
 	if err != nil {
 		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	log.Println("Worker started, waiting for geofence alerts...")
+	log.Printf("Worker started, waiting for messages on %s...", queue)
 
 	for msg := range msgs {
-		var event models.GeofenceEvent
-		if err := json.Unmarshal(msg.Body, &event); err != nil {
+		if err := handle(msg.Body); err != nil {
 			log.Printf("Failed to unmarshal message: %v", err)
 			msg.Nack(false, false) // Reject message
 			continue
 		}
 
-		handler(&event)
 		msg.Ack(false)
 	}
 
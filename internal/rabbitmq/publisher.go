@@ -13,13 +13,7 @@ import (
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 )
 
-const (
-	ExchangeName = "fleet.events"
-	QueueName    = "geofence_alerts"
-	RoutingKey   = "geofence.entry"
-)
-
-// Publisher handles RabbitMQ publishing for geofence events
+// Publisher handles RabbitMQ publishing for geofence, route and speed events
 type Publisher struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
@@ -51,43 +45,9 @@ func (p *Publisher) Connect() error {
 		return fmt.Errorf("failed to open channel: %w", err)
 	}
 
-	// Declare exchange
-	err = p.channel.ExchangeDeclare(
-		ExchangeName, // name
-		"direct",     // type
-		true,         // durable
-		false,        // auto-deleted
-		false,        // internal
-		false,        // no-wait
-		nil,          // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to declare exchange: %w", err)
-	}
-
-	// Declare queue
-	_, err = p.channel.QueueDeclare(
-		QueueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,       // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to declare queue: %w", err)
-	}
-
-	// Bind queue to exchange
-	err = p.channel.QueueBind(
-		QueueName,    // queue name
-		RoutingKey,   // routing key
-		ExchangeName, // exchange
-		false,        // no-wait
-		nil,          // arguments
-	)
-	if err != nil {
-		return fmt.Errorf("failed to bind queue: %w", err)
+	// Declare exchange, queues and bindings
+	if err := declareTopology(p.channel); err != nil {
+		return err
 	}
 
 	log.Println("Successfully connected to RabbitMQ")
@@ -96,6 +56,26 @@ func (p *Publisher) Connect() error {
 
 // PublishGeofenceEvent sends a geofence event to RabbitMQ
 func (p *Publisher) PublishGeofenceEvent(event *models.GeofenceEvent) error {
+	return p.publish(event.Event, event.VehicleID, event)
+}
+
+// PublishRouteEvent sends an off-route or back-on-route event to RabbitMQ
+func (p *Publisher) PublishRouteEvent(event *models.RouteEvent) error {
+	return p.publish(event.Event, event.VehicleID, event)
+}
+
+// PublishOverspeedEvent sends an overspeed event to RabbitMQ
+func (p *Publisher) PublishOverspeedEvent(event *models.OverspeedEvent) error {
+	return p.publish(event.Event, event.VehicleID, event)
+}
+
+// publish marshals an event and sends it with the routing key of its type
+func (p *Publisher) publish(eventType, vehicleID string, event interface{}) error {
+	routingKey, ok := routingKeys[eventType]
+	if !ok {
+		return fmt.Errorf("unknown event type: %s", eventType)
+	}
+
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
@@ -107,7 +87,7 @@ func (p *Publisher) PublishGeofenceEvent(event *models.GeofenceEvent) error {
 	err = p.channel.PublishWithContext(
 		ctx,
 		ExchangeName, // exchange
-		RoutingKey,   // routing key
+		routingKey,   // routing key
 		false,        // mandatory
 		false,        // immediate
 		amqp.Publishing{
@@ -119,7 +99,7 @@ func (p *Publisher) PublishGeofenceEvent(event *models.GeofenceEvent) error {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	log.Printf("Published geofence event for vehicle: %s", event.VehicleID)
+	log.Printf("Published %s event for vehicle: %s", eventType, vehicleID)
 	return nil
 }
 
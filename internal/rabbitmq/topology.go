@@ -0,0 +1,96 @@
+package rabbitmq
+
+import (
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+const (
+	ExchangeName = "fleet.events"
+
+	QueueName      = "geofence_alerts"
+	RouteQueueName = "route_alerts"
+	SpeedQueueName = "speed_alerts"
+
+	RoutingKeyEntry = "geofence.entry"
+	RoutingKeyExit  = "geofence.exit"
+	RoutingKeyDwell = "geofence.dwell"
+
+	RoutingKeyOffRoute    = "route.off_route"
+	RoutingKeyBackOnRoute = "route.back_on_route"
+
+	RoutingKeyOverspeed    = "speed.overspeed"
+	RoutingKeyOverspeedEnd = "speed.overspeed_end"
+)
+
+// routingKeys maps event types to their routing keys
+var routingKeys = map[string]string{
+	models.EventGeofenceEntry: RoutingKeyEntry,
+	models.EventGeofenceExit:  RoutingKeyExit,
+	models.EventGeofenceDwell: RoutingKeyDwell,
+	models.EventOffRoute:      RoutingKeyOffRoute,
+	models.EventBackOnRoute:   RoutingKeyBackOnRoute,
+	models.EventOverspeed:     RoutingKeyOverspeed,
+	models.EventOverspeedEnd:  RoutingKeyOverspeedEnd,
+}
+
+// queueBindings lists every queue and the routing keys bound to it
+var queueBindings = []struct {
+	queue string
+	keys  []string
+}{
+	{queue: QueueName, keys: []string{RoutingKeyEntry, RoutingKeyExit, RoutingKeyDwell}},
+	{queue: RouteQueueName, keys: []string{RoutingKeyOffRoute, RoutingKeyBackOnRoute}},
+	{queue: SpeedQueueName, keys: []string{RoutingKeyOverspeed, RoutingKeyOverspeedEnd}},
+}
+
+// declareTopology declares the exchange, every queue and their bindings
+func declareTopology(ch *amqp.Channel) error {
+	// Declare exchange
+	err := ch.ExchangeDeclare(
+		ExchangeName, // name
+		"direct",     // type
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	for _, binding := range queueBindings {
+		// Declare queue
+		_, err = ch.QueueDeclare(
+			binding.queue, // name
+			true,          // durable
+			false,         // delete when unused
+			false,         // exclusive
+			false,         // no-wait
+			nil,           // arguments
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare queue %s: %w", binding.queue, err)
+		}
+
+		// Bind queue to exchange
+		for _, key := range binding.keys {
+			err = ch.QueueBind(
+				binding.queue, // queue name
+				key,           // routing key
+				ExchangeName,  // exchange
+				false,         // no-wait
+				nil,           // arguments
+			)
+			if err != nil {
+				return fmt.Errorf("failed to bind queue %s to %s: %w", binding.queue, key, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	type request struct {
+		at         time.Duration // since the first request
+		key        string
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}
+
+	tests := []struct {
+		name     string
+		limit    config.RateLimit
+		requests []request
+	}{
+		{
+			name:  "burst is allowed then limited",
+			limit: config.RateLimit{Rate: 1, Burst: 3},
+			requests: []request{
+				{at: 0, allowed: true, remaining: 2, reset: time.Second},
+				{at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{at: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+			},
+		},
+		{
+			name:  "tokens refill at the rate",
+			limit: config.RateLimit{Rate: 2, Burst: 1},
+			requests: []request{
+				{at: 0, allowed: true, remaining: 0, reset: 500 * time.Millisecond},
+				{at: 250 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 250 * time.Millisecond, reset: 250 * time.Millisecond},
+				{at: 500 * time.Millisecond, allowed: true, remaining: 0, reset: 500 * time.Millisecond},
+			},
+		},
+		{
+			name:  "refill stops at the burst",
+			limit: config.RateLimit{Rate: 1, Burst: 2},
+			requests: []request{
+				{at: 0, allowed: true, remaining: 1, reset: time.Second},
+				{at: time.Hour, allowed: true, remaining: 1, reset: time.Second},
+				{at: time.Hour, allowed: true, remaining: 0, reset: 2 * time.Second},
+				{at: time.Hour, allowed: false, remaining: 0, retryAfter: time.Second, reset: 2 * time.Second},
+			},
+		},
+		{
+			name:  "keys have separate buckets",
+			limit: config.RateLimit{Rate: 0.1, Burst: 1},
+			requests: []request{
+				{at: 0, key: "a", allowed: true, remaining: 0, reset: 10 * time.Second},
+				{at: 0, key: "a", allowed: false, remaining: 0, retryAfter: 10 * time.Second, reset: 10 * time.Second},
+				{at: 0, key: "b", allowed: true, remaining: 0, reset: 10 * time.Second},
+			},
+		},
+		{
+			name:  "denied requests don't take tokens",
+			limit: config.RateLimit{Rate: 1, Burst: 1},
+			requests: []request{
+				{at: 0, allowed: true, remaining: 0, reset: time.Second},
+				{at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: time.Second},
+				{at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: time.Second},
+				{at: time.Second, allowed: true, remaining: 0, reset: time.Second},
+			},
+		},
+	}
+
+	start := time.Unix(1715000000, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.limit)
+
+			for i, r := range tt.requests {
+				got := l.Allow(r.key, start.Add(r.at))
+				want := Result{
+					Allowed:    r.allowed,
+					Limit:      tt.limit.Burst,
+					Remaining:  r.remaining,
+					RetryAfter: r.retryAfter,
+					Reset:      r.reset,
+				}
+				if got != want {
+					t.Errorf("request %d at %s: got %+v, want %+v", i, r.at, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewLimiterDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit config.RateLimit
+	}{
+		{name: "zero rate", limit: config.RateLimit{Rate: 0, Burst: 10}},
+		{name: "negative rate", limit: config.RateLimit{Rate: -1, Burst: 10}},
+		{name: "zero burst", limit: config.RateLimit{Rate: 1, Burst: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if l := NewLimiter(tt.limit); l != nil {
+				t.Errorf("got a limiter for %+v, want nil", tt.limit)
+			}
+		})
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := NewLimiter(config.RateLimit{Rate: 1, Burst: 5})
+	start := time.Unix(1715000000, 0)
+
+	l.Allow("idle", start)
+	l.Allow("busy", start.Add(3*time.Second))
+
+	// idle has refilled after 5s, busy still needs another second
+	if dropped := l.Sweep(start.Add(7 * time.Second)); dropped != 1 {
+		t.Errorf("got %d buckets dropped, want 1", dropped)
+	}
+	if n := l.Len(); n != 1 {
+		t.Errorf("got %d buckets left, want 1", n)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", PerIP(NewLimiter(config.RateLimit{Rate: 0.5, Burst: 2})), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	want := []struct {
+		status     int
+		remaining  string
+		retryAfter string
+	}{
+		{status: fiber.StatusOK, remaining: "1"},
+		{status: fiber.StatusOK, remaining: "0"},
+		{status: fiber.StatusTooManyRequests, remaining: "0", retryAfter: "2"},
+	}
+
+	for i, w := range want {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != w.status {
+			t.Errorf("request %d: got status %d, want %d", i, resp.StatusCode, w.status)
+		}
+		if got := resp.Header.Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: got X-RateLimit-Limit %q, want 2", i, got)
+		}
+		if got := resp.Header.Get("X-RateLimit-Remaining"); got != w.remaining {
+			t.Errorf("request %d: got X-RateLimit-Remaining %q, want %q", i, got, w.remaining)
+		}
+		if got := resp.Header.Get(fiber.HeaderRetryAfter); got != w.retryAfter {
+			t.Errorf("request %d: got Retry-After %q, want %q", i, got, w.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/auth"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Limits holds the limiter of each route group. A nil limiter leaves its
+// group unlimited.
+type Limits struct {
+	IP        *Limiter // per client IP, across every route
+	Default   *Limiter
+	Locations *Limiter
+	History   *Limiter
+	Streams   *Limiter
+}
+
+// NewLimits creates the limiters configured for each route group
+func NewLimits(cfg *config.Config) *Limits {
+	if !cfg.RateLimitEnabled {
+		return &Limits{}
+	}
+
+	return &Limits{
+		IP:        NewLimiter(cfg.RateLimitIP),
+		Default:   NewLimiter(cfg.RateLimitDefault),
+		Locations: NewLimiter(cfg.RateLimitLocations),
+		History:   NewLimiter(cfg.RateLimitHistory),
+		Streams:   NewLimiter(cfg.RateLimitStreams),
+	}
+}
+
+// Run sweeps idle buckets of every limiter at the given interval until
+// the context is cancelled
+func (l *Limits) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, limiter := range []*Limiter{l.IP, l.Default, l.Locations, l.History, l.Streams} {
+				if limiter != nil {
+					limiter.Sweep(now)
+				}
+			}
+		}
+	}
+}
+
+// PerIP returns a middleware limiting requests per client IP
+func PerIP(l *Limiter) fiber.Handler {
+	return middleware(l, func(c *fiber.Ctx) string {
+		return "ip:" + c.IP()
+	})
+}
+
+// PerCaller returns a middleware limiting requests per API key or JWT
+// subject. It must run after auth.Authenticate; requests without a
+// principal, or with authentication disabled, are limited per client IP.
+func PerCaller(l *Limiter) fiber.Handler {
+	return middleware(l, func(c *fiber.Ctx) string {
+		principal := auth.FromContext(c)
+		if principal == nil || principal.Method == auth.MethodNone {
+			return "ip:" + c.IP()
+		}
+		return principal.Method + ":" + principal.ID
+	})
+}
+
+// middleware takes a token from the caller's bucket, rejecting the request
+// with 429 when it is empty. The X-RateLimit-* headers describe the bucket
+// the request was counted against.
+func middleware(l *Limiter, key func(c *fiber.Ctx) string) fiber.Handler {
+	if l == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		result := l.Allow(key(c), time.Now())
+
+		c.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds(result.RetryAfter), 1)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+				Error: "rate limit exceeded",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
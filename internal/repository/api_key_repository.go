@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new APIKeyRepository instance
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey stores a new API key by the hash of its plaintext key
+func (r *APIKeyRepository) CreateAPIKey(key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (id, name, prefix, key_hash, role, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	key.CreatedAt = time.Now().Unix()
+
+	_, err := r.db.Exec(query, key.ID, key.Name, key.Prefix, keyHash, key.Role, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the API key with the given key hash, including
+// revoked and expired keys
+func (r *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, role, created_at, expires_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	key, err := scanAPIKey(r.db.QueryRow(query, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys retrieves every API key, including revoked ones
+func (r *APIKeyRepository) ListAPIKeys() ([]models.APIKey, error) {
+	query := `
+		SELECT id, name, prefix, role, created_at, expires_at, revoked_at
+		FROM api_keys
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an API key as revoked. It reports whether an active
+// key with the ID existed.
+func (r *APIKeyRepository) RevokeAPIKey(id string) (bool, error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, id, time.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// scanAPIKey scans an API key row
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var expiresAt, revokedAt sql.NullInt64
+
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Int64
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Int64
+	}
+
+	return &key, nil
+}
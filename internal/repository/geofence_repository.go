@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// ErrGeofenceExists is returned when creating a geofence with an ID that
+// is already taken
+var ErrGeofenceExists = errors.New("geofence already exists")
+
+// GeofenceRepository handles database operations for geofences
+type GeofenceRepository struct {
+	db *sql.DB
+}
+
+// NewGeofenceRepository creates a new GeofenceRepository instance
+func NewGeofenceRepository(db *sql.DB) *GeofenceRepository {
+	return &GeofenceRepository{db: db}
+}
+
+// CreateGeofence inserts a new geofence into the database
+func (r *GeofenceRepository) CreateGeofence(g *models.Geofence) error {
+	query := `
+		INSERT INTO geofences (id, name, geometry, radius, buffer, speed_limit, expires_at,
+			exit_buffer, min_pings, min_seconds, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	now := time.Now().Unix()
+	g.CreatedAt = now
+	g.UpdatedAt = now
+
+	_, err := r.db.Exec(query, g.ID, g.Name, []byte(g.Geometry), g.Radius, g.Buffer, g.SpeedLimit, g.ExpiresAt,
+		g.ExitBuffer, g.MinPings, g.MinSeconds, g.CreatedAt, g.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrGeofenceExists
+		}
+		return fmt.Errorf("failed to create geofence: %w", err)
+	}
+
+	return nil
+}
+
+// GetGeofence retrieves a geofence by ID
+func (r *GeofenceRepository) GetGeofence(id string) (*models.Geofence, error) {
+	query := `
+		SELECT id, name, geometry, radius, buffer, speed_limit, expires_at, exit_buffer, min_pings, min_seconds, created_at, updated_at
+		FROM geofences
+		WHERE id = $1
+	`
+
+	g, err := scanGeofence(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get geofence: %w", err)
+	}
+
+	return g, nil
+}
+
+// ListGeofences retrieves every geofence, including expired ones
+func (r *GeofenceRepository) ListGeofences() ([]models.Geofence, error) {
+	query := `
+		SELECT id, name, geometry, radius, buffer, speed_limit, expires_at, exit_buffer, min_pings, min_seconds, created_at, updated_at
+		FROM geofences
+		ORDER BY id ASC
+	`
+
+	return r.queryGeofences(query)
+}
+
+// ListActiveGeofences retrieves every geofence that has not expired
+func (r *GeofenceRepository) ListActiveGeofences() ([]models.Geofence, error) {
+	query := `
+		SELECT id, name, geometry, radius, buffer, speed_limit, expires_at, exit_buffer, min_pings, min_seconds, created_at, updated_at
+		FROM geofences
+		WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY id ASC
+	`
+
+	return r.queryGeofences(query, time.Now().Unix())
+}
+
+// UpdateGeofence replaces the attributes of an existing geofence. It
+// returns false when no geofence with the ID exists.
+func (r *GeofenceRepository) UpdateGeofence(g *models.Geofence) (bool, error) {
+	query := `
+		UPDATE geofences
+		SET name = $2, geometry = $3, radius = $4, buffer = $5, speed_limit = $6, expires_at = $7,
+			exit_buffer = $8, min_pings = $9, min_seconds = $10, updated_at = $11
+		WHERE id = $1
+		RETURNING created_at
+	`
+
+	g.UpdatedAt = time.Now().Unix()
+
+	err := r.db.QueryRow(query, g.ID, g.Name, []byte(g.Geometry), g.Radius, g.Buffer, g.SpeedLimit, g.ExpiresAt,
+		g.ExitBuffer, g.MinPings, g.MinSeconds, g.UpdatedAt).Scan(&g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to update geofence: %w", err)
+	}
+
+	return true, nil
+}
+
+// DeleteGeofence removes a geofence by ID. It returns false when no
+// geofence with the ID exists.
+func (r *GeofenceRepository) DeleteGeofence(id string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM geofences WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete geofence: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete geofence: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// queryGeofences runs a geofence query and scans every row
+func (r *GeofenceRepository) queryGeofences(query string, args ...interface{}) ([]models.Geofence, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list geofences: %w", err)
+	}
+	defer rows.Close()
+
+	var geofences []models.Geofence
+	for rows.Next() {
+		g, err := scanGeofence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		geofences = append(geofences, *g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return geofences, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGeofence scans a single geofence row
+func scanGeofence(row rowScanner) (*models.Geofence, error) {
+	var g models.Geofence
+	var geometry []byte
+	var radius sql.NullFloat64
+	var buffer sql.NullFloat64
+	var speedLimit sql.NullFloat64
+	var expiresAt sql.NullInt64
+	var exitBuffer sql.NullFloat64
+	var minPings sql.NullInt64
+	var minSeconds sql.NullInt64
+
+	err := row.Scan(&g.ID, &g.Name, &geometry, &radius, &buffer, &speedLimit, &expiresAt,
+		&exitBuffer, &minPings, &minSeconds, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	g.Geometry = geometry
+	g.Radius = radius.Float64
+	g.Buffer = buffer.Float64
+	g.SpeedLimit = speedLimit.Float64
+	if expiresAt.Valid {
+		g.ExpiresAt = &expiresAt.Int64
+	}
+	if exitBuffer.Valid {
+		g.ExitBuffer = &exitBuffer.Float64
+	}
+	if minPings.Valid {
+		pings := int(minPings.Int64)
+		g.MinPings = &pings
+	}
+	if minSeconds.Valid {
+		g.MinSeconds = &minSeconds.Int64
+	}
+
+	return &g, nil
+}
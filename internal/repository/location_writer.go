@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// ErrWriterClosed is returned when writing to a closed LocationWriter
+var ErrWriterClosed = errors.New("location writer is closed")
+
+// LocationWriter buffers vehicle locations and saves them in batches,
+// flushing when a batch is full or when the flush interval elapses.
+// Write blocks when the buffer is full so a slow database applies
+// backpressure instead of growing memory without bound.
+type LocationWriter struct {
+	repo          *VehicleRepository
+	batchSize     int
+	flushInterval time.Duration
+	onError       func(err error, failed int)
+
+	input chan models.VehicleLocation
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewLocationWriter creates a new LocationWriter and starts its flush
+// loop. onError is called after a flush with the number of locations that
+// could not be saved; when nil, failures are only logged.
+func NewLocationWriter(repo *VehicleRepository, batchSize int, flushInterval time.Duration, onError func(err error, failed int)) *LocationWriter {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	w := &LocationWriter{
+		repo:          repo,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		onError:       onError,
+		input:         make(chan models.VehicleLocation, batchSize*4),
+		done:          make(chan struct{}),
+	}
+
+	go w.run()
+	return w
+}
+
+// Write queues a location for the next batch
+func (w *LocationWriter) Write(loc *models.VehicleLocation) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	w.input <- *loc
+	return nil
+}
+
+// Pending returns the number of locations waiting in the buffer
+func (w *LocationWriter) Pending() int {
+	return len(w.input)
+}
+
+// Close stops accepting locations, flushes everything still buffered and
+// waits for the final flush to finish
+func (w *LocationWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.input)
+	w.mu.Unlock()
+
+	<-w.done
+	log.Println("Location writer drained")
+}
+
+// run collects locations into batches until the input channel is closed
+func (w *LocationWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.VehicleLocation, 0, w.batchSize)
+
+	for {
+		select {
+		case loc, ok := <-w.input:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+
+			batch = append(batch, loc)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush saves a batch. When the batch insert fails, locations are retried
+// one by one so a single bad row doesn't discard the whole batch.
+func (w *LocationWriter) flush(batch []models.VehicleLocation) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := w.repo.SaveLocations(batch)
+	if err == nil {
+		return
+	}
+
+	log.Printf("Failed to save batch of %d locations, retrying individually: %v", len(batch), err)
+
+	failed := 0
+	var lastErr error
+	for i := range batch {
+		if err := w.repo.SaveLocation(&batch[i]); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+
+	if failed == 0 {
+		return
+	}
+
+	if w.onError != nil {
+		w.onError(lastErr, failed)
+		return
+	}
+	log.Printf("Failed to save %d of %d locations: %v", failed, len(batch), lastErr)
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// MaintenanceRepository handles database operations for background
+// maintenance jobs on the location history
+type MaintenanceRepository struct {
+	db *sql.DB
+}
+
+// NewMaintenanceRepository creates a new MaintenanceRepository instance
+func NewMaintenanceRepository(db *sql.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db}
+}
+
+// GetState returns the stored value of a maintenance state entry, or nil
+// if it has never been set
+func (r *MaintenanceRepository) GetState(name string) (*int64, error) {
+	var value int64
+	err := r.db.QueryRow(`SELECT value FROM maintenance_state WHERE name = $1`, name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance state: %w", err)
+	}
+
+	return &value, nil
+}
+
+// SetState stores the value of a maintenance state entry
+func (r *MaintenanceRepository) SetState(name string, value int64) error {
+	query := `
+		INSERT INTO maintenance_state (name, value, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			value = EXCLUDED.value,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := r.db.Exec(query, name, value, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to set maintenance state: %w", err)
+	}
+
+	return nil
+}
+
+// GetOldestTimestamp returns the timestamp of the oldest stored location
+// at or after since, or nil when there are none
+func (r *MaintenanceRepository) GetOldestTimestamp(since int64) (*int64, error) {
+	var ts sql.NullInt64
+	err := r.db.QueryRow(`SELECT MIN(timestamp) FROM vehicle_locations WHERE timestamp >= $1`, since).Scan(&ts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oldest location: %w", err)
+	}
+	if !ts.Valid {
+		return nil, nil
+	}
+
+	return &ts.Int64, nil
+}
+
+// ListVehicleIDs returns the vehicles with locations in [startTime, endTime)
+func (r *MaintenanceRepository) ListVehicleIDs(startTime, endTime int64) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT vehicle_id
+		FROM vehicle_locations
+		WHERE timestamp >= $1 AND timestamp < $2
+	`, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vehicles: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetTrack retrieves the locations of a vehicle in [startTime, endTime)
+// with their row IDs, ordered by timestamp
+func (r *MaintenanceRepository) GetTrack(vehicleID string, startTime, endTime int64) ([]models.StoredLocation, error) {
+	query := `
+		SELECT id, ` + locationColumns + `
+		FROM vehicle_locations
+		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp < $3
+		ORDER BY timestamp ASC, id ASC
+	`
+
+	rows, err := r.db.Query(query, vehicleID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track: %w", err)
+	}
+	defer rows.Close()
+
+	var track []models.StoredLocation
+	for rows.Next() {
+		var id int64
+		loc, err := scanLocation(prefixScanner{row: rows, dest: []interface{}{&id}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		track = append(track, models.StoredLocation{ID: id, VehicleLocation: *loc})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return track, nil
+}
+
+// DeleteLocations removes the given location rows of a vehicle within
+// [startTime, endTime) and returns how many were deleted. The time range
+// lets PostgreSQL prune the partitions it has to visit.
+func (r *MaintenanceRepository) DeleteLocations(vehicleID string, startTime, endTime int64, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result, err := r.db.Exec(`
+		DELETE FROM vehicle_locations
+		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp < $3 AND id = ANY($4)
+	`, vehicleID, startTime, endTime, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete locations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// prefixScanner scans leading columns into dest before handing the
+// remaining columns to the wrapped scan destinations
+type prefixScanner struct {
+	row  rowScanner
+	dest []interface{}
+}
+
+// Scan implements rowScanner
+func (s prefixScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(s.dest, dest...)...)
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// RouteRepository handles database operations for vehicle route assignments
+type RouteRepository struct {
+	db *sql.DB
+}
+
+// NewRouteRepository creates a new RouteRepository instance
+func NewRouteRepository(db *sql.DB) *RouteRepository {
+	return &RouteRepository{db: db}
+}
+
+// AssignRoute assigns a route to a vehicle, replacing any previous assignment
+func (r *RouteRepository) AssignRoute(vehicleID, routeID string) (*models.RouteAssignment, error) {
+	query := `
+		INSERT INTO vehicle_routes (vehicle_id, route_id, assigned_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (vehicle_id) DO UPDATE
+		SET route_id = EXCLUDED.route_id, assigned_at = EXCLUDED.assigned_at
+	`
+
+	assignment := &models.RouteAssignment{
+		VehicleID:  vehicleID,
+		RouteID:    routeID,
+		AssignedAt: time.Now().Unix(),
+	}
+
+	_, err := r.db.Exec(query, assignment.VehicleID, assignment.RouteID, assignment.AssignedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign route: %w", err)
+	}
+
+	return assignment, nil
+}
+
+// GetAssignment retrieves the route assigned to a vehicle
+func (r *RouteRepository) GetAssignment(vehicleID string) (*models.RouteAssignment, error) {
+	query := `
+		SELECT vehicle_id, route_id, assigned_at
+		FROM vehicle_routes
+		WHERE vehicle_id = $1
+	`
+
+	var a models.RouteAssignment
+	err := r.db.QueryRow(query, vehicleID).Scan(&a.VehicleID, &a.RouteID, &a.AssignedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route assignment: %w", err)
+	}
+
+	return &a, nil
+}
+
+// ListAssignments retrieves every vehicle route assignment
+func (r *RouteRepository) ListAssignments() ([]models.RouteAssignment, error) {
+	query := `
+		SELECT vehicle_id, route_id, assigned_at
+		FROM vehicle_routes
+		ORDER BY vehicle_id ASC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list route assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []models.RouteAssignment
+	for rows.Next() {
+		var a models.RouteAssignment
+		if err := rows.Scan(&a.VehicleID, &a.RouteID, &a.AssignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// UnassignRoute removes the route assignment of a vehicle. It returns
+// false when the vehicle has no assigned route.
+func (r *RouteRepository) UnassignRoute(vehicleID string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM vehicle_routes WHERE vehicle_id = $1`, vehicleID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unassign route: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to unassign route: %w", err)
+	}
+
+	return affected > 0, nil
+}
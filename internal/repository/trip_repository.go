@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// tripColumns are the columns read by scanTrip, in order
+const tripColumns = `id, vehicle_id, started_at, ended_at, start_latitude, start_longitude,
+	start_place_id, start_place_name, end_latitude, end_longitude, end_place_id, end_place_name,
+	distance_meters, duration_seconds, end_reason`
+
+// TripRepository handles database operations for detected trips
+type TripRepository struct {
+	db *sql.DB
+}
+
+// NewTripRepository creates a new TripRepository instance
+func NewTripRepository(db *sql.DB) *TripRepository {
+	return &TripRepository{db: db}
+}
+
+// SaveTrip inserts a trip or updates it when it was saved while in
+// progress
+func (r *TripRepository) SaveTrip(t *models.Trip) error {
+	query := `
+		INSERT INTO trips (` + tripColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE SET
+			ended_at = EXCLUDED.ended_at,
+			end_latitude = EXCLUDED.end_latitude,
+			end_longitude = EXCLUDED.end_longitude,
+			end_place_id = EXCLUDED.end_place_id,
+			end_place_name = EXCLUDED.end_place_name,
+			distance_meters = EXCLUDED.distance_meters,
+			duration_seconds = EXCLUDED.duration_seconds,
+			end_reason = EXCLUDED.end_reason
+	`
+
+	var endLat, endLon *float64
+	if t.EndLocation != nil {
+		endLat, endLon = &t.EndLocation.Latitude, &t.EndLocation.Longitude
+	}
+	startPlaceID, startPlaceName := placeColumns(t.StartPlace)
+	endPlaceID, endPlaceName := placeColumns(t.EndPlace)
+
+	_, err := r.db.Exec(query, t.ID, t.VehicleID, t.StartedAt, t.EndedAt,
+		t.StartLocation.Latitude, t.StartLocation.Longitude, startPlaceID, startPlaceName,
+		endLat, endLon, endPlaceID, endPlaceName,
+		t.DistanceMeters, t.DurationSeconds, nullString(t.EndReason))
+	if err != nil {
+		return fmt.Errorf("failed to save trip: %w", err)
+	}
+
+	return nil
+}
+
+// ListTrips retrieves the trips of a vehicle that overlap the time range,
+// including one still in progress, ordered by start time
+func (r *TripRepository) ListTrips(vehicleID string, start, end int64) ([]models.Trip, error) {
+	query := `
+		SELECT ` + tripColumns + `
+		FROM trips
+		WHERE vehicle_id = $1 AND started_at <= $3 AND (ended_at IS NULL OR ended_at >= $2)
+		ORDER BY started_at ASC
+	`
+
+	return r.queryTrips(query, vehicleID, start, end)
+}
+
+// ListTripsInProgress retrieves every trip that has not ended yet
+func (r *TripRepository) ListTripsInProgress() ([]models.Trip, error) {
+	query := `
+		SELECT ` + tripColumns + `
+		FROM trips
+		WHERE ended_at IS NULL
+		ORDER BY vehicle_id ASC, started_at ASC
+	`
+
+	return r.queryTrips(query)
+}
+
+// queryTrips runs a trip query and scans every row
+func (r *TripRepository) queryTrips(query string, args ...interface{}) ([]models.Trip, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trips: %w", err)
+	}
+	defer rows.Close()
+
+	trips := []models.Trip{}
+	for rows.Next() {
+		t, err := scanTrip(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		trips = append(trips, *t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return trips, nil
+}
+
+// scanTrip scans a single trip row
+func scanTrip(row rowScanner) (*models.Trip, error) {
+	var t models.Trip
+	var endedAt sql.NullInt64
+	var startPlaceID, startPlaceName, endPlaceID, endPlaceName, endReason sql.NullString
+	var endLat, endLon sql.NullFloat64
+
+	err := row.Scan(&t.ID, &t.VehicleID, &t.StartedAt, &endedAt,
+		&t.StartLocation.Latitude, &t.StartLocation.Longitude, &startPlaceID, &startPlaceName,
+		&endLat, &endLon, &endPlaceID, &endPlaceName,
+		&t.DistanceMeters, &t.DurationSeconds, &endReason)
+	if err != nil {
+		return nil, err
+	}
+
+	if endedAt.Valid {
+		t.EndedAt = &endedAt.Int64
+	}
+	if endLat.Valid && endLon.Valid {
+		t.EndLocation = &models.Location{Latitude: endLat.Float64, Longitude: endLon.Float64}
+	}
+	if startPlaceID.Valid {
+		t.StartPlace = &models.Place{GeofenceID: startPlaceID.String, Name: startPlaceName.String}
+	}
+	if endPlaceID.Valid {
+		t.EndPlace = &models.Place{GeofenceID: endPlaceID.String, Name: endPlaceName.String}
+	}
+	t.EndReason = endReason.String
+
+	return &t, nil
+}
+
+// placeColumns returns the nullable ID and name columns of a place
+func placeColumns(p *models.Place) (*string, *string) {
+	if p == nil {
+		return nil, nil
+	}
+	return &p.GeofenceID, &p.Name
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
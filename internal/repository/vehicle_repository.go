@@ -2,11 +2,31 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
 
 	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
 )
 
+// locationColumns lists the vehicle_locations columns in scan order
+const locationColumns = `vehicle_id, latitude, longitude, timestamp,
+	speed, heading, hdop, accuracy, odometer, ignition, door_open`
+
+// latestUpsertChunk caps the vehicles per latest location upsert statement
+// to stay well below the PostgreSQL limit of 65535 parameters
+const latestUpsertChunk = 1000
+
+// copyColumns lists the vehicle_locations columns written by SaveLocations
+var copyColumns = []string{
+	"vehicle_id", "latitude", "longitude", "timestamp",
+	"speed", "heading", "hdop", "accuracy", "odometer", "ignition", "door_open",
+}
+
 // VehicleRepository handles database operations for vehicle locations
 type VehicleRepository struct {
 	db *sql.DB
@@ -17,39 +37,95 @@ func NewVehicleRepository(db *sql.DB) *VehicleRepository {
 	return &VehicleRepository{db: db}
 }
 
-// SaveLocation inserts a new vehicle location into the database
+// SaveLocation inserts a new vehicle location into the database and
+// updates the vehicle's latest location
 func (r *VehicleRepository) SaveLocation(loc *models.VehicleLocation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO vehicle_locations (vehicle_id, latitude, longitude, timestamp)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO vehicle_locations (` + locationColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
-	_, err := r.db.Exec(query, loc.VehicleID, loc.Latitude, loc.Longitude, loc.Timestamp)
+	_, err = tx.Exec(query,
+		loc.VehicleID, loc.Latitude, loc.Longitude, loc.Timestamp,
+		loc.Speed, loc.Heading, loc.HDOP, loc.Accuracy, loc.Odometer, loc.Ignition, loc.DoorOpen,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to save location: %w", err)
 	}
 
+	if err := upsertLatestLocations(tx, []*models.VehicleLocation{loc}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit location: %w", err)
+	}
+
+	return nil
+}
+
+// SaveLocations inserts many vehicle locations in one transaction using
+// the PostgreSQL COPY protocol and updates the latest location of every
+// vehicle in the batch
+func (r *VehicleRepository) SaveLocations(locs []models.VehicleLocation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pq.CopyIn("vehicle_locations", copyColumns...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+
+	for i := range locs {
+		loc := &locs[i]
+		_, err := stmt.Exec(
+			loc.VehicleID, loc.Latitude, loc.Longitude, loc.Timestamp,
+			loc.Speed, loc.Heading, loc.HDOP, loc.Accuracy, loc.Odometer, loc.Ignition, loc.DoorOpen,
+		)
+		if err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy location: %w", err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to flush copy: %w", err)
+	}
+
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to close copy: %w", err)
+	}
+
+	if err := upsertLatestLocations(tx, newestPerVehicle(locs)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit locations: %w", err)
+	}
+
 	return nil
 }
 
 // GetLatestLocation retrieves the most recent location for a vehicle
 func (r *VehicleRepository) GetLatestLocation(vehicleID string) (*models.VehicleLocation, error) {
 	query := `
-		SELECT vehicle_id, latitude, longitude, timestamp
-		FROM vehicle_locations
+		SELECT ` + locationColumns + `
+		FROM vehicle_latest_locations
 		WHERE vehicle_id = $1
-		ORDER BY timestamp DESC
-		LIMIT 1
 	`
 
-	var loc models.VehicleLocation
-	err := r.db.QueryRow(query, vehicleID).Scan(
-		&loc.VehicleID,
-		&loc.Latitude,
-		&loc.Longitude,
-		&loc.Timestamp,
-	)
-
+	loc, err := scanLocation(r.db.QueryRow(query, vehicleID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -58,31 +134,64 @@ func (r *VehicleRepository) GetLatestLocation(vehicleID string) (*models.Vehicle
 		return nil, fmt.Errorf("failed to get latest location: %w", err)
 	}
 
-	return &loc, nil
+	return loc, nil
+}
+
+// LatestLocationFilter narrows the vehicles returned by
+// ListLatestLocations. Zero values don't filter.
+type LatestLocationFilter struct {
+	VehicleIDs    []string
+	RouteID       string
+	UpdatedAfter  int64 // only fixes with a timestamp at or after this time
+	UpdatedBefore int64 // only fixes with a timestamp before this time
 }
 
-// GetLocationHistory retrieves location history for a vehicle within a time range
-func (r *VehicleRepository) GetLocationHistory(vehicleID string, startTime, endTime int64) ([]models.VehicleLocation, error) {
+// ListLatestLocations retrieves the latest location of every vehicle
+// matching the filter, ordered by vehicle ID
+func (r *VehicleRepository) ListLatestLocations(filter LatestLocationFilter) ([]models.VehicleLocation, error) {
+	var conditions []string
+	var args []interface{}
+
+	if len(filter.VehicleIDs) > 0 {
+		args = append(args, pq.Array(filter.VehicleIDs))
+		conditions = append(conditions, fmt.Sprintf("vehicle_id = ANY($%d)", len(args)))
+	}
+	if filter.RouteID != "" {
+		args = append(args, filter.RouteID)
+		conditions = append(conditions, fmt.Sprintf(
+			"vehicle_id IN (SELECT vehicle_id FROM vehicle_routes WHERE route_id = $%d)", len(args)))
+	}
+	if filter.UpdatedAfter > 0 {
+		args = append(args, filter.UpdatedAfter)
+		conditions = append(conditions, fmt.Sprintf("timestamp >= $%d", len(args)))
+	}
+	if filter.UpdatedBefore > 0 {
+		args = append(args, filter.UpdatedBefore)
+		conditions = append(conditions, fmt.Sprintf("timestamp < $%d", len(args)))
+	}
+
 	query := `
-		SELECT vehicle_id, latitude, longitude, timestamp
-		FROM vehicle_locations
-		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp <= $3
-		ORDER BY timestamp ASC
+		SELECT ` + locationColumns + `
+		FROM vehicle_latest_locations
 	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY vehicle_id"
 
-	rows, err := r.db.Query(query, vehicleID, startTime, endTime)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get location history: %w", err)
+		return nil, fmt.Errorf("failed to list latest locations: %w", err)
 	}
 	defer rows.Close()
 
 	var locations []models.VehicleLocation
 	for rows.Next() {
-		var loc models.VehicleLocation
-		if err := rows.Scan(&loc.VehicleID, &loc.Latitude, &loc.Longitude, &loc.Timestamp); err != nil {
+		loc, err := scanLocation(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
-		locations = append(locations, loc)
+		locations = append(locations, *loc)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -91,3 +200,251 @@ func (r *VehicleRepository) GetLocationHistory(vehicleID string, startTime, endT
 
 	return locations, nil
 }
+
+// upsertLatestLocations stores locations as the latest location of their
+// vehicles. A location older than the stored one never replaces it, so
+// late out-of-order messages can't move a vehicle back in time. Every
+// location must belong to a different vehicle.
+func upsertLatestLocations(tx *sql.Tx, locs []*models.VehicleLocation) error {
+	for start := 0; start < len(locs); start += latestUpsertChunk {
+		end := min(start+latestUpsertChunk, len(locs))
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(copyColumns))
+		for _, loc := range locs[start:end] {
+			placeholders := make([]string, len(copyColumns))
+			for i := range placeholders {
+				placeholders[i] = fmt.Sprintf("$%d", len(args)+i+1)
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+			args = append(args,
+				loc.VehicleID, loc.Latitude, loc.Longitude, loc.Timestamp,
+				loc.Speed, loc.Heading, loc.HDOP, loc.Accuracy, loc.Odometer, loc.Ignition, loc.DoorOpen,
+			)
+		}
+
+		query := `
+			INSERT INTO vehicle_latest_locations (` + locationColumns + `)
+			VALUES ` + strings.Join(values, ", ") + `
+			ON CONFLICT (vehicle_id) DO UPDATE SET
+				latitude = EXCLUDED.latitude,
+				longitude = EXCLUDED.longitude,
+				timestamp = EXCLUDED.timestamp,
+				speed = EXCLUDED.speed,
+				heading = EXCLUDED.heading,
+				hdop = EXCLUDED.hdop,
+				accuracy = EXCLUDED.accuracy,
+				odometer = EXCLUDED.odometer,
+				ignition = EXCLUDED.ignition,
+				door_open = EXCLUDED.door_open
+			WHERE vehicle_latest_locations.timestamp < EXCLUDED.timestamp
+		`
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("failed to update latest locations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// newestPerVehicle returns the most recent location of every vehicle in
+// locs, since one upsert statement can't touch the same row twice
+func newestPerVehicle(locs []models.VehicleLocation) []*models.VehicleLocation {
+	newest := make(map[string]*models.VehicleLocation)
+	order := make([]string, 0)
+
+	for i := range locs {
+		loc := &locs[i]
+		cur, ok := newest[loc.VehicleID]
+		if !ok {
+			order = append(order, loc.VehicleID)
+		}
+		if !ok || loc.Timestamp >= cur.Timestamp {
+			newest[loc.VehicleID] = loc
+		}
+	}
+
+	// Keep a stable vehicle order so concurrent batches lock rows in the
+	// same order and can't deadlock
+	sort.Strings(order)
+
+	result := make([]*models.VehicleLocation, len(order))
+	for i, id := range order {
+		result[i] = newest[id]
+	}
+	return result
+}
+
+// HistoryCursor marks the last location of a history page. The next page
+// starts after it in (timestamp, id) order.
+type HistoryCursor struct {
+	Timestamp int64
+	ID        int64
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c *HistoryCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", c.Timestamp, c.ID)))
+}
+
+// DecodeHistoryCursor parses a cursor produced by HistoryCursor.Encode
+func DecodeHistoryCursor(s string) (*HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	tsStr, idStr, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cursor HistoryCursor
+	if cursor.Timestamp, err = strconv.ParseInt(tsStr, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if cursor.ID, err = strconv.ParseInt(idStr, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return &cursor, nil
+}
+
+// GetLocationHistoryPage retrieves at most limit locations of a vehicle
+// within a time range, starting after the cursor when one is given. It
+// returns the cursor of the next page, or nil when this is the last one.
+func (r *VehicleRepository) GetLocationHistoryPage(vehicleID string, startTime, endTime int64, after *HistoryCursor, limit int) ([]models.VehicleLocation, *HistoryCursor, error) {
+	query := `
+		SELECT id, ` + locationColumns + `
+		FROM vehicle_locations
+		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp <= $3
+	`
+	args := []interface{}{vehicleID, startTime, endTime}
+
+	if after != nil {
+		query += ` AND (timestamp, id) > ($4, $5)`
+		args = append(args, after.Timestamp, after.ID)
+	}
+
+	// Fetch one extra row to find out whether another page follows
+	args = append(args, limit+1)
+	query += fmt.Sprintf(` ORDER BY timestamp ASC, id ASC LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get location history: %w", err)
+	}
+	defer rows.Close()
+
+	locations := make([]models.VehicleLocation, 0, limit)
+	var next *HistoryCursor
+	var lastID int64
+	for rows.Next() {
+		var id int64
+		loc, err := scanLocation(prefixScanner{row: rows, dest: []interface{}{&id}})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if len(locations) == limit {
+			last := locations[len(locations)-1]
+			next = &HistoryCursor{Timestamp: last.Timestamp, ID: lastID}
+			break
+		}
+
+		locations = append(locations, *loc)
+		lastID = id
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return locations, next, nil
+}
+
+// StreamLocationHistory calls fn for every location of a vehicle within a
+// time range in timestamp order without loading the range into memory.
+// Iteration stops at the first error returned by fn.
+func (r *VehicleRepository) StreamLocationHistory(vehicleID string, startTime, endTime int64, fn func(loc *models.VehicleLocation) error) error {
+	query := `
+		SELECT ` + locationColumns + `
+		FROM vehicle_locations
+		WHERE vehicle_id = $1 AND timestamp >= $2 AND timestamp <= $3
+		ORDER BY timestamp ASC, id ASC
+	`
+
+	rows, err := r.db.Query(query, vehicleID, startTime, endTime)
+	if err != nil {
+		return fmt.Errorf("failed to get location history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		loc, err := scanLocation(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(loc); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// scanLocation scans a single vehicle location row selected with
+// locationColumns
+func scanLocation(row rowScanner) (*models.VehicleLocation, error) {
+	var loc models.VehicleLocation
+	var speed, heading, hdop, accuracy, odometer sql.NullFloat64
+	var ignition, doorOpen sql.NullBool
+
+	err := row.Scan(
+		&loc.VehicleID,
+		&loc.Latitude,
+		&loc.Longitude,
+		&loc.Timestamp,
+		&speed,
+		&heading,
+		&hdop,
+		&accuracy,
+		&odometer,
+		&ignition,
+		&doorOpen,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	loc.Speed = nullFloatPtr(speed)
+	loc.Heading = nullFloatPtr(heading)
+	loc.HDOP = nullFloatPtr(hdop)
+	loc.Accuracy = nullFloatPtr(accuracy)
+	loc.Odometer = nullFloatPtr(odometer)
+	loc.Ignition = nullBoolPtr(ignition)
+	loc.DoorOpen = nullBoolPtr(doorOpen)
+
+	return &loc, nil
+}
+
+// nullFloatPtr converts a nullable float into a pointer, nil when NULL
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// nullBoolPtr converts a nullable bool into a pointer, nil when NULL
+func nullBoolPtr(v sql.NullBool) *bool {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Bool
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"encoding/base64"
+	"math"
+	"testing"
+)
+
+func FuzzHistoryCursorRoundTrip(f *testing.F) {
+	f.Add(int64(1715003456), int64(1))
+	f.Add(int64(0), int64(0))
+	f.Add(int64(-1), int64(math.MaxInt64))
+	f.Add(int64(math.MinInt64), int64(-42))
+
+	f.Fuzz(func(t *testing.T, ts, id int64) {
+		cursor := &HistoryCursor{Timestamp: ts, ID: id}
+		encoded := cursor.Encode()
+
+		if _, err := base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			t.Fatalf("cursor %q isn't URL-safe base64: %v", encoded, err)
+		}
+
+		decoded, err := DecodeHistoryCursor(encoded)
+		if err != nil {
+			t.Fatalf("DecodeHistoryCursor(%q): %v", encoded, err)
+		}
+		if *decoded != *cursor {
+			t.Errorf("round trip of %+v gave %+v", *cursor, *decoded)
+		}
+	})
+}
+
+func TestDecodeHistoryCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	for _, cursor := range []string{
+		"",
+		"not base64!",
+		encode("1715003456"),
+		encode("1715003456."),
+		encode(".12"),
+		encode("abc.12"),
+		encode("1715003456.12.3"),
+		encode("1715003456.99999999999999999999"),
+		base64.StdEncoding.EncodeToString([]byte("1715003456.12")), // padded
+	} {
+		if got, err := DecodeHistoryCursor(cursor); err == nil {
+			t.Errorf("DecodeHistoryCursor(%q) = %+v, want an error", cursor, *got)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package speed
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+const (
+	// minInterval is the shortest gap between fixes used to derive speed,
+	// shorter gaps amplify GPS noise too much
+	minInterval = 1 // in seconds
+
+	// MaxPlausibleSpeed discards derived speeds caused by GPS jumps
+	MaxPlausibleSpeed = 200.0 // in km/h
+)
+
+// episode tracks one continuous period above the speed limit
+type episode struct {
+	limit     float64
+	zone      *geofence.Fence
+	startedAt int64
+	peak      float64
+	peakLoc   models.Location
+	alerted   bool // the overspeed event has been raised
+}
+
+// vehicleState tracks the previous fix and the current overspeed episode
+// of one vehicle
+type vehicleState struct {
+	lastLatitude  float64
+	lastLongitude float64
+	lastTimestamp int64
+	checkedAt     int64 // timestamp of the last fix checked for overspeed
+	episode       *episode
+}
+
+// Monitor derives vehicle speed from consecutive fixes and raises an
+// overspeed event as soon as a vehicle has exceeded the applicable speed
+// limit for the configured duration, then an overspeed_end event once it
+// is back under the limit. The limit is the lowest speed limit of the
+// geofence zones the vehicle is in, or the global limit outside any zone.
+type Monitor struct {
+	checker     *geofence.Checker
+	globalLimit float64 // in km/h, 0 disables the global limit
+	minDuration int64   // in seconds
+
+	mu       sync.Mutex
+	vehicles map[string]*vehicleState
+}
+
+// NewMonitor creates a new speed monitor
+func NewMonitor(checker *geofence.Checker, globalLimit float64, minDuration time.Duration) *Monitor {
+	return &Monitor{
+		checker:     checker,
+		globalLimit: globalLimit,
+		minDuration: int64(minDuration / time.Second),
+		vehicles:    make(map[string]*vehicleState),
+	}
+}
+
+// DeriveSpeed returns the speed the device reported or, when it did not
+// report one, the speed derived from the distance and time since the
+// vehicle's previous fix. The derived speed is not written to loc so it is
+// never stored or served as if the device measured it. Returns nil when
+// no plausible speed can be derived.
+func (m *Monitor) DeriveSpeed(loc *models.VehicleLocation) *float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.vehicles[loc.VehicleID]
+	if !ok {
+		m.vehicles[loc.VehicleID] = &vehicleState{
+			lastLatitude:  loc.Latitude,
+			lastLongitude: loc.Longitude,
+			lastTimestamp: loc.Timestamp,
+		}
+		return loc.Speed
+	}
+
+	elapsed := loc.Timestamp - state.lastTimestamp
+	if elapsed <= 0 {
+		// Out-of-order or duplicate fix
+		return loc.Speed
+	}
+
+	speed := loc.Speed
+	if speed == nil && elapsed >= minInterval {
+		distance := geofence.Distance(state.lastLatitude, state.lastLongitude, loc.Latitude, loc.Longitude)
+		kmh := distance / float64(elapsed) * 3.6
+		if kmh <= MaxPlausibleSpeed {
+			kmh = math.Round(kmh*10) / 10
+			speed = &kmh
+		}
+	}
+
+	state.lastLatitude = loc.Latitude
+	state.lastLongitude = loc.Longitude
+	state.lastTimestamp = loc.Timestamp
+
+	return speed
+}
+
+// CheckOverspeed updates the vehicle's overspeed episode with the location
+// and its speed, as returned by DeriveSpeed, and returns an overspeed event when the episode reaches the minimum
+// duration, or an overspeed_end event when an alerted episode ends. Fixes
+// not newer than the last one checked for the vehicle are ignored.
+func (m *Monitor) CheckOverspeed(loc *models.VehicleLocation, kmh *float64) *models.OverspeedEvent {
+	if kmh == nil {
+		return nil
+	}
+	speed := *kmh
+	limit, zone := m.limitAt(loc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.vehicles[loc.VehicleID]
+	if !ok {
+		state = &vehicleState{}
+		m.vehicles[loc.VehicleID] = state
+	}
+
+	if loc.Timestamp <= state.checkedAt {
+		return nil
+	}
+	state.checkedAt = loc.Timestamp
+
+	if limit > 0 && speed > limit {
+		if state.episode == nil {
+			state.episode = &episode{limit: limit, zone: zone, startedAt: loc.Timestamp}
+		}
+
+		ep := state.episode
+		if speed > ep.peak {
+			ep.peak = speed
+			ep.peakLoc = models.Location{Latitude: loc.Latitude, Longitude: loc.Longitude}
+		}
+		if limit < ep.limit {
+			ep.limit = limit
+			ep.zone = zone
+		}
+
+		if !ep.alerted && loc.Timestamp-ep.startedAt >= m.minDuration {
+			ep.alerted = true
+			return ep.event(loc, models.EventOverspeed)
+		}
+		return nil
+	}
+
+	ep := state.episode
+	if ep == nil {
+		return nil
+	}
+	state.episode = nil
+
+	if !ep.alerted {
+		return nil
+	}
+	return ep.event(loc, models.EventOverspeedEnd)
+}
+
+// event builds an overspeed event of the given type for the episode as of
+// the location
+func (ep *episode) event(loc *models.VehicleLocation, eventType string) *models.OverspeedEvent {
+	event := &models.OverspeedEvent{
+		VehicleID:       loc.VehicleID,
+		Event:           eventType,
+		SpeedLimit:      ep.limit,
+		PeakSpeed:       ep.peak,
+		DurationSeconds: loc.Timestamp - ep.startedAt,
+		StartedAt:       ep.startedAt,
+		Location:        ep.peakLoc,
+		Timestamp:       loc.Timestamp,
+	}
+	if ep.zone != nil {
+		event.GeofenceID = ep.zone.ID
+		event.GeofenceName = ep.zone.Name
+	}
+
+	return event
+}
+
+// limitAt returns the speed limit that applies at the location and the
+// zone it comes from, or nil when the global limit applies
+func (m *Monitor) limitAt(loc *models.VehicleLocation) (float64, *geofence.Fence) {
+	var zone *geofence.Fence
+	for _, fence := range m.checker.IsInsideGeofence(loc) {
+		if fence.SpeedLimit > 0 && (zone == nil || fence.SpeedLimit < zone.SpeedLimit) {
+			zone = fence
+		}
+	}
+
+	if zone != nil {
+		return zone.SpeedLimit, zone
+	}
+	return m.globalLimit, nil
+}
@@ -0,0 +1,312 @@
+package trips
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// vehicleState tracks the trip a vehicle is on and the stop it may be
+// making
+type vehicleState struct {
+	last *models.VehicleLocation
+
+	trip  *models.Trip // nil between trips
+	saved bool         // the trip has been returned for saving before
+	odo   Odometer
+
+	stop           *models.VehicleLocation // first stationary fix of the current stop
+	distanceAtStop float64
+}
+
+// Detector splits each vehicle's location stream into trips.
+//
+// A vehicle is moving when its speed is at least the moving speed, or,
+// for fixes without a speed, when it moved further than the jitter
+// distance since the previous fix. A trip starts at the last fix before
+// the vehicle starts moving and ends where it stopped when:
+//
+//   - the ignition is switched off
+//   - it has been stationary inside a terminal geofence for the terminal
+//     stop duration
+//   - it has been stationary for the stop duration
+//   - no location arrived for the stop duration
+//
+// Trips shorter than the minimum distance are dropped, so a bus moved
+// around a depot doesn't make a trip.
+type Detector struct {
+	checker        *geofence.Checker
+	terminals      map[string]bool
+	movingSpeed    float64 // in km/h
+	jitterDistance float64 // in meters
+	minDistance    float64 // in meters
+	stopTime       int64   // in seconds
+	terminalStop   int64   // in seconds
+
+	mu       sync.Mutex
+	vehicles map[string]*vehicleState
+}
+
+// NewDetector creates a new trip detector
+func NewDetector(checker *geofence.Checker, cfg *config.Config) *Detector {
+	terminals := make(map[string]bool)
+	for _, id := range strings.Split(cfg.TripTerminalGeofences, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			terminals[id] = true
+		}
+	}
+
+	return &Detector{
+		checker:        checker,
+		terminals:      terminals,
+		movingSpeed:    cfg.TripMovingSpeed,
+		jitterDistance: cfg.TripJitterDistance,
+		minDistance:    cfg.TripMinDistance,
+		stopTime:       int64(cfg.TripStopTime / time.Second),
+		terminalStop:   int64(cfg.TripTerminalStopTime / time.Second),
+		vehicles:       make(map[string]*vehicleState),
+	}
+}
+
+// Store persists detected trips
+type Store interface {
+	SaveTrip(trip *models.Trip) error
+	ListTripsInProgress() ([]models.Trip, error)
+}
+
+// History reads stored vehicle locations in timestamp order
+type History interface {
+	StreamLocationHistory(vehicleID string, start, end int64, fn func(loc *models.VehicleLocation) error) error
+}
+
+// Restore resumes the trips saved while in progress, typically before the
+// server restarted, by replaying the stored locations since each started.
+// Trips that ended in the meantime are saved. It returns the number of
+// trips resumed and must run before live locations are processed.
+func (d *Detector) Restore(store Store, history History, now int64) (int, error) {
+	inProgress, err := store.ListTripsInProgress()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range inProgress {
+		trip := inProgress[i]
+
+		d.mu.Lock()
+		d.vehicles[trip.VehicleID] = &vehicleState{
+			trip:  &trip,
+			saved: true,
+			odo:   NewOdometer(d.jitterDistance),
+		}
+		d.mu.Unlock()
+
+		err := history.StreamLocationHistory(trip.VehicleID, trip.StartedAt, now, func(loc *models.VehicleLocation) error {
+			for _, t := range d.Process(loc) {
+				if err := store.SaveTrip(t); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore trip %s: %w", trip.ID, err)
+		}
+	}
+
+	return len(inProgress), nil
+}
+
+// Process adds a location to its vehicle's stream and returns the trips
+// that should be saved: a trip once it reaches the minimum distance, and
+// again when it ends. Locations not newer than the vehicle's previous
+// location are ignored.
+func (d *Detector) Process(loc *models.VehicleLocation) []*models.Trip {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.vehicles[loc.VehicleID]
+	if !ok {
+		state = &vehicleState{odo: NewOdometer(d.jitterDistance)}
+		d.vehicles[loc.VehicleID] = state
+	}
+
+	if state.last != nil && loc.Timestamp <= state.last.Timestamp {
+		return nil
+	}
+
+	var save []*models.Trip
+
+	// A gap in the stream ends the trip where the vehicle was last seen
+	gap := state.last != nil && loc.Timestamp-state.last.Timestamp >= d.stopTime
+	if gap && state.trip != nil {
+		at := state.last
+		if state.stop != nil {
+			at = state.stop
+		}
+		if trip := d.end(state, at, models.TripEndGap); trip != nil {
+			save = append(save, trip)
+		}
+	}
+
+	moving := d.moving(state.last, loc)
+	ignitionOff := loc.Ignition != nil && !*loc.Ignition
+
+	switch {
+	case state.trip == nil:
+		if moving && !ignitionOff {
+			origin := loc
+			if state.last != nil && !gap {
+				origin = state.last
+			}
+			d.start(state, origin)
+			state.odo.Add(loc.Latitude, loc.Longitude)
+		}
+
+	default:
+		state.odo.Add(loc.Latitude, loc.Longitude)
+
+		// Speed spikes from GPS noise don't end a stop until the vehicle
+		// has actually left the spot
+		if moving && state.stop != nil &&
+			geofence.Distance(state.stop.Latitude, state.stop.Longitude, loc.Latitude, loc.Longitude) <= d.jitterDistance {
+			moving = false
+		}
+
+		if moving && !ignitionOff {
+			state.stop = nil
+			break
+		}
+
+		if state.stop == nil {
+			stop := *loc
+			state.stop = &stop
+			state.distanceAtStop = state.odo.Total()
+		}
+		stopped := loc.Timestamp - state.stop.Timestamp
+
+		var reason string
+		switch {
+		case ignitionOff:
+			reason = models.TripEndIgnitionOff
+		case stopped >= d.terminalStop && d.inTerminal(state.stop):
+			reason = models.TripEndTerminal
+		case stopped >= d.stopTime:
+			reason = models.TripEndStopped
+		}
+		if reason != "" {
+			if trip := d.end(state, state.stop, reason); trip != nil {
+				save = append(save, trip)
+			}
+		}
+	}
+
+	if state.trip != nil && !state.saved && state.odo.Total() >= d.minDistance {
+		state.saved = true
+		trip := *state.trip
+		trip.DistanceMeters = state.odo.Total()
+		trip.DurationSeconds = loc.Timestamp - trip.StartedAt
+		save = append(save, &trip)
+	}
+
+	last := *loc
+	state.last = &last
+	return save
+}
+
+// start opens a trip at the given location
+func (d *Detector) start(state *vehicleState, origin *models.VehicleLocation) {
+	state.trip = &models.Trip{
+		ID:            uuid.NewString(),
+		VehicleID:     origin.VehicleID,
+		StartedAt:     origin.Timestamp,
+		StartLocation: models.Location{Latitude: origin.Latitude, Longitude: origin.Longitude},
+		StartPlace:    d.place(origin),
+	}
+	state.saved = false
+	state.stop = nil
+	state.odo = NewOdometer(d.jitterDistance)
+	state.odo.Add(origin.Latitude, origin.Longitude)
+}
+
+// end closes the current trip at the given location and returns it, or
+// nil when it is too short to keep
+func (d *Detector) end(state *vehicleState, at *models.VehicleLocation, reason string) *models.Trip {
+	trip := state.trip
+	distance := state.odo.Total()
+	if state.stop != nil && at == state.stop {
+		distance = state.distanceAtStop
+	}
+
+	endedAt := at.Timestamp
+	trip.EndedAt = &endedAt
+	trip.EndLocation = &models.Location{Latitude: at.Latitude, Longitude: at.Longitude}
+	trip.EndPlace = d.place(at)
+	trip.DistanceMeters = distance
+	trip.DurationSeconds = endedAt - trip.StartedAt
+	trip.EndReason = reason
+
+	saved := state.saved
+	state.trip = nil
+	state.saved = false
+	state.stop = nil
+
+	if !saved && distance < d.minDistance {
+		return nil
+	}
+	return trip
+}
+
+// moving reports whether the vehicle was moving at loc
+func (d *Detector) moving(prev, loc *models.VehicleLocation) bool {
+	return isMoving(prev, loc, d.movingSpeed, d.jitterDistance)
+}
+
+// isMoving reports whether a vehicle was moving at loc: its speed is at
+// least movingSpeed or, without a speed, it moved further than jitter
+// meters since prev
+func isMoving(prev, loc *models.VehicleLocation, movingSpeed, jitter float64) bool {
+	if loc.Speed != nil {
+		return *loc.Speed >= movingSpeed
+	}
+	if prev == nil {
+		return false
+	}
+	return geofence.Distance(prev.Latitude, prev.Longitude, loc.Latitude, loc.Longitude) > jitter
+}
+
+// inTerminal reports whether a location is inside a terminal geofence
+func (d *Detector) inTerminal(loc *models.VehicleLocation) bool {
+	for _, fence := range d.checker.IsInsideGeofence(loc) {
+		if d.terminals[fence.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// place returns the geofence a location is in, preferring terminals, or
+// nil when it is outside every geofence
+func (d *Detector) place(loc *models.VehicleLocation) *models.Place {
+	fences := d.checker.IsInsideGeofence(loc)
+	if len(fences) == 0 {
+		return nil
+	}
+
+	sort.Slice(fences, func(i, j int) bool {
+		ti, tj := d.terminals[fences[i].ID], d.terminals[fences[j].ID]
+		if ti != tj {
+			return ti
+		}
+		return fences[i].ID < fences[j].ID
+	})
+
+	return &models.Place{GeofenceID: fences[0].ID, Name: fences[0].Name}
+}
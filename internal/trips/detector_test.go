@@ -0,0 +1,184 @@
+package trips
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+const (
+	originLat       = -6.2
+	originLon       = 106.8
+	metersPerDegree = 111320.0
+)
+
+// track builds the location stream of a vehicle driving north from the
+// origin, one fix at a time
+type track struct {
+	ts     int64
+	meters float64 // north of the origin
+	fixes  []models.VehicleLocation
+}
+
+func (tr *track) fix(speed float64, ignition *bool) {
+	tr.fixes = append(tr.fixes, models.VehicleLocation{
+		VehicleID: "B1234XYZ",
+		Latitude:  originLat + tr.meters/metersPerDegree,
+		Longitude: originLon,
+		Timestamp: tr.ts,
+		Speed:     &speed,
+		Ignition:  ignition,
+	})
+}
+
+// park adds a stationary fix every interval seconds for duration seconds
+func (tr *track) park(duration, interval int64) *track {
+	for elapsed := interval; elapsed <= duration; elapsed += interval {
+		tr.ts += interval
+		tr.fix(0, nil)
+	}
+	return tr
+}
+
+// drive adds one fix per second, each step meters further
+func (tr *track) drive(steps int, step float64) *track {
+	for i := 0; i < steps; i++ {
+		tr.ts++
+		tr.meters += step
+		tr.fix(step*3.6, nil)
+	}
+	return tr
+}
+
+// ignitionOff adds a stationary fix with the ignition off after interval
+// seconds
+func (tr *track) ignitionOff(interval int64) *track {
+	off := false
+	tr.ts += interval
+	tr.fix(0, &off)
+	return tr
+}
+
+// spike adds a stationary fix reporting speed after interval seconds, as
+// GPS noise does
+func (tr *track) spike(interval int64, speed float64) *track {
+	tr.ts += interval
+	tr.fix(speed, nil)
+	return tr
+}
+
+// gap skips seconds without adding a fix
+func (tr *track) gap(seconds int64) *track {
+	tr.ts += seconds
+	return tr
+}
+
+// newTrack starts a track with a stationary fix at the origin at time 0
+func newTrack() *track {
+	tr := &track{}
+	tr.fix(0, nil)
+	return tr
+}
+
+func TestDetector(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal bool // the geofence 1km north of the origin is a terminal
+		track    *track
+		want     []string
+	}{
+		{
+			name:  "stopping ends the trip where the vehicle stopped",
+			track: newTrack().park(10, 10).drive(50, 20).park(400, 10),
+			want:  []string{"open 10", "stopped 10-70 1000m"},
+		},
+		{
+			name:  "stopping short of the stop duration doesn't end the trip",
+			track: newTrack().park(10, 10).drive(25, 20).park(100, 10).drive(25, 20).park(400, 10),
+			want:  []string{"open 10", "stopped 10-170 1000m"},
+		},
+		{
+			name:  "trip shorter than the minimum distance is dropped",
+			track: newTrack().park(10, 10).drive(5, 20).park(400, 10),
+			want:  nil,
+		},
+		{
+			name:  "ignition off ends the trip at once",
+			track: newTrack().park(10, 10).drive(50, 20).ignitionOff(10).park(60, 10),
+			want:  []string{"open 10", "ignition_off 10-70 1000m"},
+		},
+		{
+			name:     "stopping in a terminal ends the trip sooner",
+			terminal: true,
+			track:    newTrack().park(10, 10).drive(50, 20).park(70, 10),
+			want:     []string{"open 10", "terminal 10-70 1000m"},
+		},
+		{
+			name:  "stopping in a geofence that isn't a terminal waits for the stop duration",
+			track: newTrack().park(10, 10).drive(50, 20).park(290, 10),
+			want:  []string{"open 10"},
+		},
+		{
+			name:  "a gap ends the trip at the last fix",
+			track: newTrack().park(10, 10).drive(50, 20).gap(400).park(10, 10),
+			want:  []string{"open 10", "gap 10-60 1000m"},
+		},
+		{
+			name:  "speed spikes don't end a stop",
+			track: newTrack().park(10, 10).drive(50, 20).park(200, 10).spike(10, 30).park(100, 10),
+			want:  []string{"open 10", "stopped 10-70 1000m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				GeofenceID:           "terminal",
+				GeofenceName:         "Terminal",
+				GeofenceLatitude:     originLat + 1000/metersPerDegree,
+				GeofenceLongitude:    originLon,
+				GeofenceRadius:       50,
+				TripMovingSpeed:      5,
+				TripJitterDistance:   15,
+				TripMinDistance:      200,
+				TripStopTime:         300 * time.Second,
+				TripTerminalStopTime: 60 * time.Second,
+			}
+			if tt.terminal {
+				cfg.TripTerminalGeofences = "terminal"
+			}
+
+			checker, err := geofence.NewChecker(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			detector := NewDetector(checker, cfg)
+
+			var got []string
+			for i := range tt.track.fixes {
+				for _, trip := range detector.Process(&tt.track.fixes[i]) {
+					got = append(got, describeTrip(trip))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got trips %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// describeTrip summarises a saved trip, with the distance rounded to 10m
+func describeTrip(trip *models.Trip) string {
+	if trip.EndedAt == nil {
+		return fmt.Sprintf("open %d", trip.StartedAt)
+	}
+	return fmt.Sprintf("%s %d-%d %.0fm", trip.EndReason, trip.StartedAt, *trip.EndedAt,
+		math.Round(trip.DistanceMeters/10)*10)
+}
@@ -0,0 +1,47 @@
+package trips
+
+import (
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// Odometer sums the distance along a track while ignoring GPS jitter: a
+// fix only counts once it is further than the jitter distance from the
+// last counted fix, so a parked vehicle doesn't accumulate distance.
+type Odometer struct {
+	jitter float64 // in meters
+	anchor *models.Location
+	total  float64
+}
+
+// NewOdometer creates an odometer ignoring moves up to jitter meters
+func NewOdometer(jitter float64) Odometer {
+	return Odometer{jitter: jitter}
+}
+
+// Add moves the odometer to a fix and returns the distance it added
+func (o *Odometer) Add(lat, lon float64) float64 {
+	if o.anchor == nil {
+		o.anchor = &models.Location{Latitude: lat, Longitude: lon}
+		return 0
+	}
+
+	d := geofence.Distance(o.anchor.Latitude, o.anchor.Longitude, lat, lon)
+	if d <= o.jitter {
+		return 0
+	}
+
+	o.total += d
+	o.anchor = &models.Location{Latitude: lat, Longitude: lon}
+	return d
+}
+
+// Reset moves the odometer to a fix without counting the distance to it
+func (o *Odometer) Reset(lat, lon float64) {
+	o.anchor = &models.Location{Latitude: lat, Longitude: lon}
+}
+
+// Total returns the distance counted so far, in meters
+func (o *Odometer) Total() float64 {
+	return o.total
+}
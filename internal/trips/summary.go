@@ -0,0 +1,137 @@
+package trips
+
+import (
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/geofence"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/speed"
+)
+
+// Summarizer computes odometer-style figures for a vehicle from its stored
+// location history, using the same moving and jitter rules as the trip
+// detector
+type Summarizer struct {
+	history        History
+	movingSpeed    float64 // in km/h
+	jitterDistance float64 // in meters
+	maxGap         int64   // in seconds, longer intervals count as neither moving nor idle
+	minStop        int64   // in seconds
+}
+
+// NewSummarizer creates a new vehicle summarizer
+func NewSummarizer(history History, cfg *config.Config) *Summarizer {
+	return &Summarizer{
+		history:        history,
+		movingSpeed:    cfg.TripMovingSpeed,
+		jitterDistance: cfg.TripJitterDistance,
+		maxGap:         int64(cfg.TripStopTime / time.Second),
+		minStop:        int64(cfg.SummaryMinStopTime / time.Second),
+	}
+}
+
+// Summarize streams a vehicle's history between start and end and returns
+// its movement summary.
+//
+// Each interval between two fixes counts towards moving or idle time by
+// the state at its end; intervals with the ignition off or longer than the
+// stop duration, when the device was likely offline, count towards
+// neither. Distance is the haversine sum over moving intervals, ignoring
+// moves within the jitter distance, and the maximum speed only considers
+// fixes that left the jitter distance at a plausible speed. A stop is a
+// stationary period of at least the minimum stop duration after the
+// vehicle has moved.
+func (s *Summarizer) Summarize(vehicleID string, start, end int64) (*models.VehicleSummary, error) {
+	summary := &models.VehicleSummary{VehicleID: vehicleID, Start: start, End: end}
+	odo := NewOdometer(s.jitterDistance)
+
+	var prev *models.VehicleLocation
+	var stop *models.VehicleLocation // first stationary fix, nil while moving
+	var anchorAt int64               // time of the fix the odometer counts from
+
+	// A vehicle parked when the range starts hasn't made a stop yet
+	stopCounted := true
+
+	err := s.history.StreamLocationHistory(vehicleID, start, end, func(loc *models.VehicleLocation) error {
+		summary.Points++
+
+		moving := isMoving(prev, loc, s.movingSpeed, s.jitterDistance)
+		ignitionOff := loc.Ignition != nil && !*loc.Ignition
+
+		// As in the detector, speed spikes from GPS noise don't end a stop
+		// until the vehicle has actually left the spot
+		if moving && stop != nil &&
+			geofence.Distance(stop.Latitude, stop.Longitude, loc.Latitude, loc.Longitude) <= s.jitterDistance {
+			moving = false
+		}
+
+		var interval int64
+		if prev != nil {
+			interval = loc.Timestamp - prev.Timestamp
+		}
+
+		switch {
+		case prev == nil || interval > s.maxGap || ignitionOff:
+			odo.Reset(loc.Latitude, loc.Longitude)
+			anchorAt = loc.Timestamp
+		case moving:
+			summary.MovingSeconds += interval
+			if d := odo.Add(loc.Latitude, loc.Longitude); d > 0 {
+				if v := fixSpeed(loc, d, loc.Timestamp-anchorAt); v > summary.MaxSpeed {
+					summary.MaxSpeed = v
+				}
+				anchorAt = loc.Timestamp
+			}
+		default:
+			summary.IdleSeconds += interval
+			odo.Reset(loc.Latitude, loc.Longitude)
+			anchorAt = loc.Timestamp
+		}
+
+		if moving {
+			stop = nil
+			stopCounted = false
+		} else {
+			if stop == nil {
+				stop = loc
+			}
+			if !stopCounted && loc.Timestamp-stop.Timestamp >= s.minStop {
+				summary.Stops++
+				stopCounted = true
+			}
+		}
+
+		prev = loc
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.DistanceMeters = odo.Total()
+	if summary.MovingSeconds > 0 {
+		summary.AvgSpeed = summary.DistanceMeters / float64(summary.MovingSeconds) * 3.6
+	}
+
+	return summary, nil
+}
+
+// fixSpeed returns the speed at a fix the odometer counted, distance
+// meters and elapsed seconds after the previously counted fix: the
+// reported speed, or the speed over that distance when there is none.
+// Implausible speeds from GPS jumps return 0.
+func fixSpeed(loc *models.VehicleLocation, distance float64, elapsed int64) float64 {
+	var kmh float64
+	switch {
+	case loc.Speed != nil:
+		kmh = *loc.Speed
+	case elapsed > 0:
+		kmh = distance / float64(elapsed) * 3.6
+	}
+
+	if kmh > speed.MaxPlausibleSpeed {
+		return 0
+	}
+	return kmh
+}
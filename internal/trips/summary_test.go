@@ -0,0 +1,135 @@
+package trips
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/config"
+	"github.com/fuadsyah/transjakarta_fleet_management/internal/models"
+)
+
+// storedHistory serves a fixed location history like the repository does
+type storedHistory []models.VehicleLocation
+
+func (h storedHistory) StreamLocationHistory(vehicleID string, start, end int64, fn func(loc *models.VehicleLocation) error) error {
+	for i := range h {
+		if h[i].VehicleID == vehicleID && h[i].Timestamp >= start && h[i].Timestamp <= end {
+			if err := fn(&h[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func summarize(t *testing.T, fixes []models.VehicleLocation, start, end int64) *models.VehicleSummary {
+	t.Helper()
+
+	s := NewSummarizer(storedHistory(fixes), &config.Config{
+		TripMovingSpeed:    5,
+		TripJitterDistance: 15,
+		TripStopTime:       300 * time.Second,
+		SummaryMinStopTime: 60 * time.Second,
+	})
+	summary, err := s.Summarize("B1234XYZ", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+// near reports whether got is within 1% of want
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= math.Abs(want)*0.01
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		track    *track
+		distance float64 // in meters
+		moving   int64
+		idle     int64
+		maxSpeed float64
+		stops    int
+	}{
+		{
+			name:     "drive then park",
+			track:    newTrack().park(10, 10).drive(50, 20).park(400, 10),
+			distance: 1000, moving: 50, idle: 410, maxSpeed: 72, stops: 1,
+		},
+		{
+			name:     "short pause isn't a stop",
+			track:    newTrack().drive(25, 20).park(50, 10).drive(25, 20),
+			distance: 1000, moving: 50, idle: 50, maxSpeed: 72, stops: 0,
+		},
+		{
+			name:     "gap counts as neither moving nor idle",
+			track:    newTrack().drive(10, 20).gap(400).drive(10, 20),
+			distance: 380, moving: 19, idle: 0, maxSpeed: 72, stops: 0,
+		},
+		{
+			name:     "ignition off counts as neither moving nor idle",
+			track:    newTrack().park(10, 10).drive(50, 20).ignitionOff(10).park(60, 10),
+			distance: 1000, moving: 50, idle: 70, maxSpeed: 72, stops: 1,
+		},
+		{
+			name:     "speed spike doesn't move a parked vehicle",
+			track:    newTrack().drive(50, 20).park(200, 10).spike(10, 30).park(100, 10),
+			distance: 1000, moving: 50, idle: 310, maxSpeed: 72, stops: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixes := tt.track.fixes
+			got := summarize(t, fixes, 0, fixes[len(fixes)-1].Timestamp)
+
+			if got.Points != len(fixes) || !near(got.DistanceMeters, tt.distance) ||
+				got.MovingSeconds != tt.moving || got.IdleSeconds != tt.idle ||
+				!near(got.MaxSpeed, tt.maxSpeed) || got.Stops != tt.stops {
+				t.Errorf("got %+v, want %d points, %.0fm, %ds moving, %ds idle, max %.0f km/h, %d stops",
+					*got, len(fixes), tt.distance, tt.moving, tt.idle, tt.maxSpeed, tt.stops)
+			}
+			if want := got.DistanceMeters / float64(got.MovingSeconds) * 3.6; got.AvgSpeed != want {
+				t.Errorf("got average %.2f km/h, want %.2f", got.AvgSpeed, want)
+			}
+		})
+	}
+}
+
+func TestSummarizeWithoutReportedSpeed(t *testing.T) {
+	// A fix every 10s, 100m apart (36 km/h), with one GPS jump of 10km
+	var fixes []models.VehicleLocation
+	for i, meters := range []float64{0, 100, 200, 10200, 10300, 10400} {
+		fixes = append(fixes, models.VehicleLocation{
+			VehicleID: "B1234XYZ",
+			Latitude:  originLat + meters/metersPerDegree,
+			Longitude: originLon,
+			Timestamp: int64(i) * 10,
+		})
+	}
+
+	got := summarize(t, fixes, 0, 50)
+	if !near(got.MaxSpeed, 36) {
+		t.Errorf("got max speed %.1f km/h, want 36 with the jump ignored", got.MaxSpeed)
+	}
+	if got.MovingSeconds != 50 {
+		t.Errorf("got %ds moving, want 50", got.MovingSeconds)
+	}
+}
+
+func TestSummarizeRange(t *testing.T) {
+	fixes := newTrack().park(10, 10).drive(50, 20).park(400, 10).fixes
+
+	// Only the drive itself: the range starts at the last parked fix
+	got := summarize(t, fixes, 10, 60)
+	if got.Start != 10 || got.End != 60 || got.Points != 51 || got.MovingSeconds != 50 || got.IdleSeconds != 0 || got.Stops != 0 {
+		t.Errorf("got %+v for the drive only", *got)
+	}
+
+	if got := summarize(t, fixes, 1000, 2000); got.Points != 0 || got.DistanceMeters != 0 || got.AvgSpeed != 0 {
+		t.Errorf("got %+v for a range without history", *got)
+	}
+}
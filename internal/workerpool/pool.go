@@ -0,0 +1,93 @@
+package workerpool
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Pool runs tasks on a fixed number of workers. Every task is assigned to
+// a worker by hashing its key, so tasks with the same key run in the order
+// they were submitted while tasks with different keys run in parallel.
+type Pool struct {
+	queues []chan func()
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a pool with the given number of workers, each with its own
+// queue holding up to queueSize tasks
+func New(workers, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+
+	return p
+}
+
+// Submit queues a task on the worker owning the key. It blocks while that
+// worker's queue is full, applying backpressure to the caller. It returns
+// false when the pool has been closed and the task was not queued.
+func (p *Pool) Submit(key string, task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.queues[p.shard(key)] <- task
+	return true
+}
+
+// QueueDepth returns the number of tasks waiting across all workers
+func (p *Pool) QueueDepth() int {
+	depth := 0
+	for _, q := range p.queues {
+		depth += len(q)
+	}
+	return depth
+}
+
+// Close stops accepting tasks and waits until every queued task has run
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// shard returns the index of the worker owning the key
+func (p *Pool) shard(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// work runs the tasks of one queue until it is closed
+func (p *Pool) work(queue chan func()) {
+	defer p.wg.Done()
+
+	for task := range queue {
+		task()
+	}
+}
@@ -0,0 +1,121 @@
+package workerpool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolKeepsOrderPerKey(t *testing.T) {
+	p := New(4, 8)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+
+	const perKey = 200
+	for i := 0; i < perKey; i++ {
+		for v := 0; v < 10; v++ {
+			key := fmt.Sprintf("B%04d", v)
+			i := i
+			p.Submit(key, func() {
+				mu.Lock()
+				seen[key] = append(seen[key], i)
+				mu.Unlock()
+			})
+		}
+	}
+	p.Close()
+
+	for key, got := range seen {
+		if len(got) != perKey {
+			t.Errorf("%s: ran %d tasks, want %d", key, len(got), perKey)
+		}
+		for i, v := range got {
+			if v != i {
+				t.Errorf("%s: task %d ran at position %d", key, v, i)
+				break
+			}
+		}
+	}
+}
+
+func TestPoolSubmitBlocksWhenQueueFull(t *testing.T) {
+	p := New(1, 1)
+	defer p.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p.Submit("a", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// The worker is busy, so this fills the one-task queue
+	p.Submit("a", func() {})
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit("a", func() {})
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit returned while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if depth := p.QueueDepth(); depth != 1 {
+		t.Errorf("got queue depth %d, want 1", depth)
+	}
+
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("Submit still blocked after the worker caught up")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := New(2, 100)
+
+	var mu sync.Mutex
+	ran := 0
+	for i := 0; i < 50; i++ {
+		p.Submit(fmt.Sprint(i), func() {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			ran++
+			mu.Unlock()
+		})
+	}
+
+	p.Close()
+	if ran != 50 {
+		t.Errorf("Close returned after %d of 50 tasks ran", ran)
+	}
+
+	if p.Submit("late", func() { t.Error("task ran after Close") }) {
+		t.Error("Submit accepted a task after Close")
+	}
+	p.Close() // closing twice is a no-op
+}
+
+func TestNewClampsSettings(t *testing.T) {
+	p := New(0, -1)
+	defer p.Close()
+
+	if len(p.queues) != 1 || cap(p.queues[0]) != 0 {
+		t.Errorf("got %d workers with queue size %d, want 1 unbuffered worker", len(p.queues), cap(p.queues[0]))
+	}
+
+	done := make(chan struct{})
+	p.Submit("a", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task didn't run on an unbuffered pool")
+	}
+}
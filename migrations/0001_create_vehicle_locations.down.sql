@@ -0,0 +1,2 @@
+-- Dropping the parent table drops every attached partition
+DROP TABLE IF EXISTS vehicle_locations;
@@ -0,0 +1,63 @@
+-- Telemetry columns added to the original unpartitioned table, so older
+-- databases can be converted below without losing data
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS speed DOUBLE PRECISION;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS heading DOUBLE PRECISION;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS hdop DOUBLE PRECISION;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS accuracy DOUBLE PRECISION;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS odometer DOUBLE PRECISION;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS ignition BOOLEAN;
+ALTER TABLE IF EXISTS vehicle_locations ADD COLUMN IF NOT EXISTS door_open BOOLEAN;
+
+-- Move an unpartitioned vehicle_locations table out of the way so it can
+-- be replaced by the partitioned table below
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_class WHERE relname = 'vehicle_locations' AND relkind = 'r') THEN
+        ALTER TABLE vehicle_locations RENAME TO vehicle_locations_legacy;
+        ALTER TABLE vehicle_locations_legacy RENAME CONSTRAINT vehicle_locations_pkey TO vehicle_locations_legacy_pkey;
+        ALTER SEQUENCE IF EXISTS vehicle_locations_id_seq RENAME TO vehicle_locations_legacy_id_seq;
+        DROP INDEX IF EXISTS idx_vehicle_locations_vehicle_id;
+        DROP INDEX IF EXISTS idx_vehicle_locations_timestamp;
+        DROP INDEX IF EXISTS idx_vehicle_locations_vehicle_timestamp;
+    END IF;
+END $$;
+
+-- Table for storing vehicle locations, range-partitioned by timestamp.
+-- Dated partitions are created by the server's partition manager; rows
+-- outside every partition land in the default partition.
+CREATE TABLE IF NOT EXISTS vehicle_locations (
+    id BIGSERIAL,
+    vehicle_id VARCHAR(50) NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    timestamp BIGINT NOT NULL,
+    speed DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    hdop DOUBLE PRECISION,
+    accuracy DOUBLE PRECISION,
+    odometer DOUBLE PRECISION,
+    ignition BOOLEAN,
+    door_open BOOLEAN,
+    PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE IF NOT EXISTS vehicle_locations_default PARTITION OF vehicle_locations DEFAULT;
+
+-- Indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_vehicle_locations_vehicle_id ON vehicle_locations(vehicle_id);
+CREATE INDEX IF NOT EXISTS idx_vehicle_locations_timestamp ON vehicle_locations(timestamp);
+CREATE INDEX IF NOT EXISTS idx_vehicle_locations_vehicle_timestamp ON vehicle_locations(vehicle_id, timestamp DESC);
+
+-- Copy rows from the unpartitioned table into the default partition. The
+-- partition manager moves them into dated partitions as it creates them.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_class WHERE relname = 'vehicle_locations_legacy' AND relkind = 'r') THEN
+        INSERT INTO vehicle_locations (vehicle_id, latitude, longitude, timestamp,
+            speed, heading, hdop, accuracy, odometer, ignition, door_open)
+        SELECT vehicle_id, latitude, longitude, timestamp,
+            speed, heading, hdop, accuracy, odometer, ignition, door_open
+        FROM vehicle_locations_legacy;
+        DROP TABLE vehicle_locations_legacy;
+    END IF;
+END $$;
@@ -0,0 +1,25 @@
+-- Table for storing geofences managed through the /geofences API
+CREATE TABLE IF NOT EXISTS geofences (
+    id VARCHAR(100) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    geometry JSONB NOT NULL,
+    radius DOUBLE PRECISION,
+    buffer DOUBLE PRECISION,
+    speed_limit DOUBLE PRECISION,
+    expires_at BIGINT,
+    exit_buffer DOUBLE PRECISION,
+    min_pings INTEGER,
+    min_seconds BIGINT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+-- Columns added after the geofences table was introduced, for databases
+-- created before migrations existed
+ALTER TABLE geofences ADD COLUMN IF NOT EXISTS buffer DOUBLE PRECISION;
+ALTER TABLE geofences ADD COLUMN IF NOT EXISTS speed_limit DOUBLE PRECISION;
+ALTER TABLE geofences ADD COLUMN IF NOT EXISTS exit_buffer DOUBLE PRECISION;
+ALTER TABLE geofences ADD COLUMN IF NOT EXISTS min_pings INTEGER;
+ALTER TABLE geofences ADD COLUMN IF NOT EXISTS min_seconds BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_geofences_expires_at ON geofences(expires_at);
@@ -0,0 +1,8 @@
+-- Table for storing the corridor geofence each vehicle is assigned to
+CREATE TABLE IF NOT EXISTS vehicle_routes (
+    vehicle_id VARCHAR(50) PRIMARY KEY,
+    route_id VARCHAR(100) NOT NULL,
+    assigned_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_routes_route_id ON vehicle_routes(route_id);
@@ -0,0 +1,6 @@
+-- Table for storing the progress of background maintenance jobs
+CREATE TABLE IF NOT EXISTS maintenance_state (
+    name VARCHAR(100) PRIMARY KEY,
+    value BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
@@ -0,0 +1,26 @@
+-- Table for storing the most recent location of every vehicle, upserted on
+-- ingest so current positions can be read without scanning the history
+CREATE TABLE IF NOT EXISTS vehicle_latest_locations (
+    vehicle_id VARCHAR(50) PRIMARY KEY,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    timestamp BIGINT NOT NULL,
+    speed DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    hdop DOUBLE PRECISION,
+    accuracy DOUBLE PRECISION,
+    odometer DOUBLE PRECISION,
+    ignition BOOLEAN,
+    door_open BOOLEAN
+);
+
+CREATE INDEX IF NOT EXISTS idx_vehicle_latest_locations_timestamp ON vehicle_latest_locations(timestamp);
+
+-- Seed the table from the existing history
+INSERT INTO vehicle_latest_locations (vehicle_id, latitude, longitude, timestamp,
+    speed, heading, hdop, accuracy, odometer, ignition, door_open)
+SELECT DISTINCT ON (vehicle_id) vehicle_id, latitude, longitude, timestamp,
+    speed, heading, hdop, accuracy, odometer, ignition, door_open
+FROM vehicle_locations
+ORDER BY vehicle_id, timestamp DESC
+ON CONFLICT (vehicle_id) DO NOTHING;
@@ -0,0 +1,12 @@
+-- Table for storing API keys. Only the SHA-256 hash of each key is kept;
+-- the prefix identifies a key without revealing it.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(100) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(20) NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT,
+    revoked_at BIGINT
+);
@@ -0,0 +1,22 @@
+-- Table for storing the trips detected from vehicle locations. A trip
+-- without ended_at is still in progress.
+CREATE TABLE IF NOT EXISTS trips (
+    id VARCHAR(100) PRIMARY KEY,
+    vehicle_id VARCHAR(50) NOT NULL,
+    started_at BIGINT NOT NULL,
+    ended_at BIGINT,
+    start_latitude DOUBLE PRECISION NOT NULL,
+    start_longitude DOUBLE PRECISION NOT NULL,
+    start_place_id VARCHAR(100),
+    start_place_name VARCHAR(255),
+    end_latitude DOUBLE PRECISION,
+    end_longitude DOUBLE PRECISION,
+    end_place_id VARCHAR(100),
+    end_place_name VARCHAR(255),
+    distance_meters DOUBLE PRECISION NOT NULL,
+    duration_seconds BIGINT NOT NULL,
+    end_reason VARCHAR(20)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_vehicle_started_at ON trips(vehicle_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_trips_in_progress ON trips(vehicle_id) WHERE ended_at IS NULL;
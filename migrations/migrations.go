@@ -0,0 +1,14 @@
+// Package migrations embeds the versioned SQL schema migrations.
+//
+// Each migration is a pair of files named NNNN_description.up.sql and
+// NNNN_description.down.sql. Versions are applied in ascending order and
+// recorded in the schema_migrations table. Released migrations must never
+// be edited; schema changes ship as a new, higher version.
+package migrations
+
+import "embed"
+
+// FS holds every migration file
+//
+//go:embed *.sql
+var FS embed.FS